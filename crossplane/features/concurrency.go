@@ -0,0 +1,38 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package features
+
+import (
+	"context"
+	"sync"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultConcurrency limits the number of claims that are processed in
+// parallel if no limit is configured.
+const defaultConcurrency = 10
+
+// forEachClaimConcurrently calls fn for every claim with at most limit
+// concurrent calls and waits until all calls have returned. fn is passed the
+// index of the claim. The returned errors have the same order as claims.
+func forEachClaimConcurrently(ctx context.Context, claims []client.Object, limit int, fn func(ctx context.Context, i int, claim client.Object) error) []error {
+	if limit < 1 {
+		limit = defaultConcurrency
+	}
+	errs := make([]error, len(claims))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, claim := range claims {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = fn(ctx, i, claim)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
@@ -4,6 +4,8 @@
 package features
 
 import (
+	"context"
+	"sync"
 	"time"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -14,6 +16,18 @@ import (
 type WaitConfig struct {
 	waitForOptions                          []wait.Option
 	ignoreComposedByCompositionResourceName []string
+	allowRemainingByCompositionResourceName []string
+	readinessByGroupKind                    map[schema.GroupKind]ReadinessPredicate
+	readinessByCompositionResourceName      map[string]ReadinessPredicate
+	kindsWithoutConditions                  *KindRegistry
+	concurrency                             int
+	watch                                   bool
+	printYAML                               bool
+	timingsExportPath                       string
+
+	// timingObserver is set while the resource tree of a single claim is
+	// waited for.
+	timingObserver *timingObserver
 }
 
 // Apply the given waitopts.
@@ -42,29 +56,180 @@ func WaitIgnoreComposedByCompositionResourceName(names ...string) WaitOption {
 	}
 }
 
-// groupKindsWithoutConditions that don't have synced or ready conditions.
+// WaitAllowRemainingByCompositionResourceName causes the composed resources
+// with the given name to be allowed to remain after their claim has been
+// deleted, for example managed resources with the Orphan deletion policy.
+func WaitAllowRemainingByCompositionResourceName(names ...string) WaitOption {
+	return func(c *WaitConfig) {
+		c.allowRemainingByCompositionResourceName = append(c.allowRemainingByCompositionResourceName, names...)
+	}
+}
+
+// WaitWithReadinessForKind registers a ReadinessPredicate for all objects of
+// the given group kind. It replaces the check of the "Synced" and "Ready"
+// conditions for these objects in a single WaitFor operation.
+func WaitWithReadinessForKind(kind schema.GroupKind, predicate ReadinessPredicate) WaitOption {
+	return func(c *WaitConfig) {
+		if c.readinessByGroupKind == nil {
+			c.readinessByGroupKind = map[schema.GroupKind]ReadinessPredicate{}
+		}
+		c.readinessByGroupKind[kind] = predicate
+	}
+}
+
+// WaitWithReadinessForCompositionResourceName registers a ReadinessPredicate
+// for the composed resources with the given name. It takes precedence over
+// predicates registered with WaitWithReadinessForKind.
+func WaitWithReadinessForCompositionResourceName(name string, predicate ReadinessPredicate) WaitOption {
+	return func(c *WaitConfig) {
+		if c.readinessByCompositionResourceName == nil {
+			c.readinessByCompositionResourceName = map[string]ReadinessPredicate{}
+		}
+		c.readinessByCompositionResourceName[name] = predicate
+	}
+}
+
+// WaitWithKindRegistry defines the registry of kinds without conditions that
+// is used by a WaitFor operation. It takes precedence over a registry stored in
+// the context.
+func WaitWithKindRegistry(r *KindRegistry) WaitOption {
+	return func(c *WaitConfig) {
+		c.kindsWithoutConditions = r
+	}
+}
+
+// WaitWithConcurrency limits the number of claims that are processed in
+// parallel by operations on multiple claims.
+func WaitWithConcurrency(n int) WaitOption {
+	return func(c *WaitConfig) {
+		c.concurrency = n
+	}
+}
+
+// WaitWithTimingsExport causes WaitForClaimReady and WaitForClaimsReady to
+// write all readiness timings of the context to path after waiting. The file
+// is written as CSV if path has the extension ".csv" and as JSON otherwise.
+func WaitWithTimingsExport(path string) WaitOption {
+	return func(c *WaitConfig) {
+		c.timingsExportPath = path
+	}
+}
+
+// WaitWithWatch causes the resource tree to be watched instead of polled.
+// Readiness is only re-evaluated if the claim, the composite or one of the
+// composed resources changes. It falls back to polling if a watch cannot be
+// established.
+func WaitWithWatch() WaitOption {
+	return func(c *WaitConfig) {
+		c.watch = true
+	}
+}
+
+// WaitWithYAMLOutput causes the full YAML of every unready resource to be
+// printed in addition to the readiness report if a WaitFor operation fails.
+func WaitWithYAMLOutput() WaitOption {
+	return func(c *WaitConfig) {
+		c.printYAML = true
+	}
+}
+
+// KindRegistry is a concurrency-safe set of group kinds that don't have synced
+// or ready conditions. Objects of these kinds are always considered ready.
 // Use GK instead of GVK because it should apply to all schema versions.
+type KindRegistry struct {
+	mu    sync.RWMutex
+	kinds map[schema.GroupKind]struct{}
+}
+
+// NewKindRegistry creates a new registry that contains the given kinds.
+func NewKindRegistry(kinds ...schema.GroupKind) *KindRegistry {
+	r := &KindRegistry{kinds: make(map[schema.GroupKind]struct{}, len(kinds))}
+	r.Register(kinds...)
+	return r
+}
+
+// Register adds kinds to the registry.
+func (r *KindRegistry) Register(kinds ...schema.GroupKind) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, kind := range kinds {
+		r.kinds[kind] = struct{}{}
+	}
+}
+
+// Has returns true if kind is registered.
+func (r *KindRegistry) Has(kind schema.GroupKind) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.kinds[kind]
+	return ok
+}
+
+// Clone returns an independent copy of the registry.
+func (r *KindRegistry) Clone() *KindRegistry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c := &KindRegistry{kinds: make(map[schema.GroupKind]struct{}, len(r.kinds))}
+	for kind := range r.kinds {
+		c.kinds[kind] = struct{}{}
+	}
+	return c
+}
+
+// defaultKindsWithoutConditions is used if neither a WaitOption nor the
+// context specify a KindRegistry.
 //
 // Extend this list using RegisterKindWithoutCondition
-var groupKindsWithoutConditions = map[schema.GroupKind]interface{}{
-	{Group: "aws.crossplane.io", Kind: "ProviderConfig"}:              nil,
-	{Group: "gitlab.crossplane.io", Kind: "ProviderConfig"}:           nil,
-	{Group: "grafana.crossplane.io", Kind: "ProviderConfig"}:          nil,
-	{Group: "argocd.crossplane.io", Kind: "ProviderConfig"}:           nil,
-	{Group: "helm.crossplane.io", Kind: "ProviderConfig"}:             nil,
-	{Group: "kubernetes.crossplane.io", Kind: "ProviderConfig"}:       nil,
-	{Group: "aws.upbound.io", Kind: "ProviderConfig"}:                 nil,
-	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:         nil,
-	{Group: "apiextensions.crossplane.io", Kind: "EnvironmentConfig"}: nil,
-	{Group: "apiextensions.crossplane.io", Kind: "Usage"}:             nil,
+var defaultKindsWithoutConditions = NewKindRegistry(
+	schema.GroupKind{Group: "aws.crossplane.io", Kind: "ProviderConfig"},
+	schema.GroupKind{Group: "gitlab.crossplane.io", Kind: "ProviderConfig"},
+	schema.GroupKind{Group: "grafana.crossplane.io", Kind: "ProviderConfig"},
+	schema.GroupKind{Group: "argocd.crossplane.io", Kind: "ProviderConfig"},
+	schema.GroupKind{Group: "helm.crossplane.io", Kind: "ProviderConfig"},
+	schema.GroupKind{Group: "kubernetes.crossplane.io", Kind: "ProviderConfig"},
+	schema.GroupKind{Group: "aws.upbound.io", Kind: "ProviderConfig"},
+	schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"},
+	schema.GroupKind{Group: "apiextensions.crossplane.io", Kind: "EnvironmentConfig"},
+	schema.GroupKind{Group: "apiextensions.crossplane.io", Kind: "Usage"},
+)
+
+// DefaultKindRegistry returns the package-wide registry of kinds without
+// conditions. Use Clone to derive a registry that is scoped to a single test.
+func DefaultKindRegistry() *KindRegistry {
+	return defaultKindsWithoutConditions
 }
 
+// RegisterKindWithoutCondition adds kind to the default registry.
 func RegisterKindWithoutCondition(kind schema.GroupKind) {
-	groupKindsWithoutConditions[kind] = nil
+	defaultKindsWithoutConditions.Register(kind)
 }
 
+// RegisterKindsWithoutCondition adds kinds to the default registry.
 func RegisterKindsWithoutCondition(kinds []schema.GroupKind) {
-	for _, kind := range kinds {
-		RegisterKindWithoutCondition(kind)
+	defaultKindsWithoutConditions.Register(kinds...)
+}
+
+type kindRegistryContextKey struct{}
+
+// ContextWithKindRegistry returns a copy of ctx that carries r. WaitFor
+// operations that receive the context use r instead of the default registry.
+func ContextWithKindRegistry(ctx context.Context, r *KindRegistry) context.Context {
+	return context.WithValue(ctx, kindRegistryContextKey{}, r)
+}
+
+// KindRegistryFromContext returns the registry stored in ctx or the default
+// registry if there is none.
+func KindRegistryFromContext(ctx context.Context) *KindRegistry {
+	if r, ok := ctx.Value(kindRegistryContextKey{}).(*KindRegistry); ok && r != nil {
+		return r
 	}
+	return defaultKindsWithoutConditions
+}
+
+// waitForOptionsWithContext returns a copy of the configured wait options
+// that is bound to ctx. The copy can be used concurrently.
+func (c *WaitConfig) waitForOptionsWithContext(ctx context.Context) []wait.Option {
+	opts := make([]wait.Option, 0, len(c.waitForOptions)+1)
+	opts = append(opts, c.waitForOptions...)
+	return append(opts, wait.WithContext(ctx))
 }
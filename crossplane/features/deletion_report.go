@@ -0,0 +1,148 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package features
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/klient"
+)
+
+// usageListGVKs of all known crossplane Usage APIs. Usages protect objects
+// from being deleted as long as they are in use.
+var usageListGVKs = []schema.GroupVersionKind{
+	{Group: "apiextensions.crossplane.io", Version: "v1beta1", Kind: "UsageList"},
+	{Group: "apiextensions.crossplane.io", Version: "v1alpha1", Kind: "UsageList"},
+	{Group: "protection.crossplane.io", Version: "v1beta1", Kind: "UsageList"},
+	{Group: "protection.crossplane.io", Version: "v1beta1", Kind: "ClusterUsageList"},
+}
+
+// DeletionReportRow describes why a single object has not been deleted yet.
+type DeletionReportRow struct {
+	GroupVersionKind  schema.GroupVersionKind
+	Namespace         string
+	Name              string
+	DeletionTimestamp *time.Time
+	Finalizers        []string
+	// Owners are formatted as Kind/name.
+	Owners []string
+	// BlockingUsages are formatted as Kind/name or Kind/namespace/name.
+	BlockingUsages []string
+}
+
+// DeletionReport lists the objects of a resource tree that still exist.
+type DeletionReport struct {
+	Rows []DeletionReportRow
+}
+
+// newDeletionReport for all existing objects. Usages that protect any of the
+// objects are looked up on the cluster. The report is returned along with the
+// error if the usages cannot be listed.
+func newDeletionReport(ctx context.Context, kube klient.Client, objects []client.Object) (*DeletionReport, error) {
+	usages, err := listUsages(ctx, kube)
+	r := &DeletionReport{}
+	for _, o := range objects {
+		if o == nil || o.GetName() == "" {
+			continue // not found on the cluster
+		}
+		row := DeletionReportRow{
+			GroupVersionKind: o.GetObjectKind().GroupVersionKind(),
+			Namespace:        o.GetNamespace(),
+			Name:             o.GetName(),
+			Finalizers:       o.GetFinalizers(),
+		}
+		if ts := o.GetDeletionTimestamp(); ts != nil {
+			row.DeletionTimestamp = &ts.Time
+		}
+		for _, owner := range o.GetOwnerReferences() {
+			row.Owners = append(row.Owners, owner.Kind+"/"+owner.Name)
+		}
+		for _, u := range usages {
+			if isUsageOf(u, o) {
+				row.BlockingUsages = append(row.BlockingUsages, strings.Join(nonEmpty(u.GetKind(), u.GetNamespace(), u.GetName()), "/"))
+			}
+		}
+		r.Rows = append(r.Rows, row)
+	}
+	return r, err
+}
+
+// String renders the report as a compact table.
+func (r *DeletionReport) String() string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tDELETING-SINCE\tFINALIZERS\tOWNERS\tBLOCKED-BY-USAGES")
+	for _, row := range r.Rows {
+		deleting := "-"
+		if row.DeletionTimestamp != nil {
+			deleting = row.DeletionTimestamp.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			row.GroupVersionKind.GroupKind().String(),
+			strings.Join(nonEmpty(row.Namespace, row.Name), "/"),
+			deleting,
+			orDash(strings.Join(row.Finalizers, ",")),
+			orDash(strings.Join(row.Owners, ",")),
+			orDash(strings.Join(row.BlockingUsages, ",")),
+		)
+	}
+	_ = w.Flush()
+	return buf.String()
+}
+
+// listUsages of all Usage APIs that are installed on the cluster.
+func listUsages(ctx context.Context, kube klient.Client) ([]unstructured.Unstructured, error) {
+	var usages []unstructured.Unstructured
+	for _, gvk := range usageListGVKs {
+		l := &unstructured.UnstructuredList{}
+		l.SetGroupVersionKind(gvk)
+		if err := kube.Resources().GetControllerRuntimeClient().List(ctx, l); err != nil {
+			if meta.IsNoMatchError(err) {
+				continue // API is not installed
+			}
+			return nil, err
+		}
+		usages = append(usages, l.Items...)
+	}
+	return usages, nil
+}
+
+// isUsageOf returns true if usage references o by name in spec.of. Namespaced
+// Usages only protect objects in their own namespace unless spec.of names a
+// namespace explicitly.
+func isUsageOf(usage unstructured.Unstructured, o client.Object) bool {
+	of, found, _ := unstructured.NestedMap(usage.Object, "spec", "of")
+	if !found {
+		return false
+	}
+	ofObj := unstructured.Unstructured{Object: of}
+	name, _, _ := unstructured.NestedString(of, "resourceRef", "name")
+	namespace, _, _ := unstructured.NestedString(of, "resourceRef", "namespace")
+	if namespace == "" {
+		namespace = usage.GetNamespace()
+	}
+	gvk := o.GetObjectKind().GroupVersionKind()
+	return ofObj.GetAPIVersion() == gvk.GroupVersion().String() && ofObj.GetKind() == gvk.Kind && name == o.GetName() &&
+		(namespace == "" || namespace == o.GetNamespace())
+}
+
+func nonEmpty(s ...string) []string {
+	out := make([]string, 0, len(s))
+	for _, v := range s {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,293 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package features
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	xpclaim "github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/crossplane/resources"
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/klient"
+)
+
+// FieldExpectation is an expectation on the value at a field path of an
+// object. Field paths use the crossplane fieldpath syntax, for example
+// "status.atProvider.conditions[0].type".
+type FieldExpectation struct {
+	// Path of the field.
+	Path string
+	// Description of the expected value used in failure messages.
+	Description string
+
+	check func(value any, exists bool) bool
+}
+
+// FieldEquals expects the field to be equal to value. Values are compared by
+// their JSON representation, so numbers of different types are equal if
+// they have the same value.
+func FieldEquals(path string, value any) FieldExpectation {
+	want, wantErr := normalizeJSON(value)
+	return FieldExpectation{
+		Path:        path,
+		Description: fmt.Sprintf("equals %s", formatFieldValue(value)),
+		check: func(got any, exists bool) bool {
+			if !exists || wantErr != nil {
+				return false
+			}
+			got, err := normalizeJSON(got)
+			return err == nil && reflect.DeepEqual(got, want)
+		},
+	}
+}
+
+// FieldMatches expects the field to be a string that matches the regular
+// expression pattern. It panics if pattern cannot be compiled.
+func FieldMatches(path, pattern string) FieldExpectation {
+	re := regexp.MustCompile(pattern)
+	return FieldExpectation{
+		Path:        path,
+		Description: fmt.Sprintf("matches %q", pattern),
+		check: func(got any, exists bool) bool {
+			s, ok := got.(string)
+			return exists && ok && re.MatchString(s)
+		},
+	}
+}
+
+// FieldExists expects the field to be set.
+func FieldExists(path string) FieldExpectation {
+	return FieldExpectation{
+		Path:        path,
+		Description: "exists",
+		check: func(_ any, exists bool) bool {
+			return exists
+		},
+	}
+}
+
+// FieldNotExists expects the field to be unset.
+func FieldNotExists(path string) FieldExpectation {
+	return FieldExpectation{
+		Path:        path,
+		Description: "does not exist",
+		check: func(_ any, exists bool) bool {
+			return !exists
+		},
+	}
+}
+
+// FieldGreaterThan expects the field to be a number greater than value.
+func FieldGreaterThan(path string, value float64) FieldExpectation {
+	return numericFieldExpectation(path, ">", value, func(got float64) bool { return got > value })
+}
+
+// FieldGreaterOrEqual expects the field to be a number greater than or equal
+// to value.
+func FieldGreaterOrEqual(path string, value float64) FieldExpectation {
+	return numericFieldExpectation(path, ">=", value, func(got float64) bool { return got >= value })
+}
+
+// FieldLessThan expects the field to be a number less than value.
+func FieldLessThan(path string, value float64) FieldExpectation {
+	return numericFieldExpectation(path, "<", value, func(got float64) bool { return got < value })
+}
+
+// FieldLessOrEqual expects the field to be a number less than or equal to
+// value.
+func FieldLessOrEqual(path string, value float64) FieldExpectation {
+	return numericFieldExpectation(path, "<=", value, func(got float64) bool { return got <= value })
+}
+
+func numericFieldExpectation(path, operator string, value float64, cmp func(got float64) bool) FieldExpectation {
+	return FieldExpectation{
+		Path:        path,
+		Description: fmt.Sprintf("%s %s", operator, strconv.FormatFloat(value, 'g', -1, 64)),
+		check: func(got any, exists bool) bool {
+			n, ok := toFloat(got)
+			return exists && ok && cmp(n)
+		},
+	}
+}
+
+// AssertClaimFields returns a [sigs.k8s.io/e2e-framework/pkg/features.Func]
+// that evaluates expectations against the live claim.
+func AssertClaimFields(claim client.Object, expectations ...FieldExpectation) features.Func {
+	return assertFields(claim, OnClaim(), expectations)
+}
+
+// AssertCompositeFields returns a
+// [sigs.k8s.io/e2e-framework/pkg/features.Func] that evaluates expectations
+// against the live composite of claim.
+func AssertCompositeFields(claim client.Object, expectations ...FieldExpectation) features.Func {
+	return assertFields(claim, OnComposite(), expectations)
+}
+
+// AssertComposedFields returns a [sigs.k8s.io/e2e-framework/pkg/features.Func]
+// that evaluates expectations against the live composed resource of claim
+// with the given composition resource name.
+func AssertComposedFields(claim client.Object, compositionResourceName string, composedGVK schema.GroupVersionKind, expectations ...FieldExpectation) features.Func {
+	return assertFields(claim, OnComposed(compositionResourceName, composedGVK), expectations)
+}
+
+// TreeTarget selects an object of the resource tree of a claim.
+type TreeTarget struct {
+	description string
+	get         func(ctx context.Context, kube klient.Client, claim client.Object) (*unstructured.Unstructured, error)
+}
+
+func (t TreeTarget) String() string {
+	return t.description
+}
+
+// OnClaim targets the claim itself.
+func OnClaim() TreeTarget {
+	return TreeTarget{
+		description: "claim",
+		get: func(ctx context.Context, kube klient.Client, claim client.Object) (*unstructured.Unstructured, error) {
+			claimOnCluster, err := getClaim(ctx, kube, claim)
+			if err != nil {
+				return nil, err
+			}
+			return &claimOnCluster.Unstructured, nil
+		},
+	}
+}
+
+// OnComposite targets the composite of the claim.
+func OnComposite() TreeTarget {
+	return TreeTarget{
+		description: "composite",
+		get: func(ctx context.Context, kube klient.Client, claim client.Object) (*unstructured.Unstructured, error) {
+			claimOnCluster, err := getClaim(ctx, kube, claim)
+			if err != nil {
+				return nil, err
+			}
+			composite := &unstructured.Unstructured{}
+			if err := resources.GetCompositeFromClaim(ctx, kube, claimOnCluster, composite); err != nil {
+				return nil, errors.Wrap(err, "cannot get composite")
+			}
+			return composite, nil
+		},
+	}
+}
+
+// OnComposed targets the composed resource of the claim with the given
+// composition resource name.
+func OnComposed(compositionResourceName string, composedGVK schema.GroupVersionKind) TreeTarget {
+	return TreeTarget{
+		description: fmt.Sprintf("composed resource %q", compositionResourceName),
+		get: func(ctx context.Context, kube klient.Client, claim client.Object) (*unstructured.Unstructured, error) {
+			composed := &unstructured.Unstructured{}
+			composed.SetGroupVersionKind(composedGVK)
+			if err := resources.GetComposedFromClaim(ctx, kube, claim, compositionResourceName, composed); err != nil {
+				return nil, errors.Wrapf(err, "cannot get composed resource %q", compositionResourceName)
+			}
+			return composed, nil
+		},
+	}
+}
+
+func getClaim(ctx context.Context, kube klient.Client, claim client.Object) (*xpclaim.Unstructured, error) {
+	claimOnCluster := xpclaim.New(xpclaim.WithGroupVersionKind(claim.GetObjectKind().GroupVersionKind()))
+	if err := klient.Get(ctx, kube, claim.GetName(), claim.GetNamespace(), claimOnCluster); err != nil {
+		return nil, errors.Wrap(err, "cannot get claim")
+	}
+	return claimOnCluster, nil
+}
+
+func assertFields(claim client.Object, target TreeTarget, expectations []FieldExpectation) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		// Set the namespace to the default test namespace if not already set
+		if err := prepareObjects(cfg.Client(), cfg.Namespace(), claim); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		o, err := target.get(ctx, cfg.Client(), claim)
+		if err != nil {
+			t.Errorf("assess failed: %s\n", err.Error())
+			return ctx
+		}
+		if diff := evaluateFieldExpectations(o, expectations); diff != "" {
+			t.Errorf("field expectations failed for %s %s:\n%s", o.GroupVersionKind().GroupKind().String(), client.ObjectKeyFromObject(o).String(), diff)
+		}
+		return ctx
+	}
+}
+
+// evaluateFieldExpectations against o and return a diff-style description of
+// all failed expectations or an empty string if all are fulfilled.
+func evaluateFieldExpectations(o *unstructured.Unstructured, expectations []FieldExpectation) string {
+	buf := &bytes.Buffer{}
+	paved := fieldpath.Pave(o.Object)
+	for _, e := range expectations {
+		value, err := paved.GetValue(e.Path)
+		exists := err == nil
+		if e.check(value, exists) {
+			continue
+		}
+		actual := "<not set>"
+		if exists {
+			actual = formatFieldValue(value)
+		}
+		fmt.Fprintf(buf, "  %s\n  - expected: %s\n  + actual:   %s\n", e.Path, e.Description, actual)
+	}
+	return buf.String()
+}
+
+func formatFieldValue(v any) string {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(raw)
+}
+
+// normalizeJSON converts v into the types used by encoding/json when
+// unmarshalling into an interface.
+func normalizeJSON(v any) (any, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -4,12 +4,16 @@
 package features
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	xpclaim "github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
-	"github.com/crossplane/function-sdk-go/errors"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	apimachinerywait "k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/utils/ptr"
@@ -26,20 +30,25 @@ import (
 // It uses foreground cascading delete policy to delete the claim and the
 // underlying resources.
 // It does not cancel if the passed timeout duration is zero.
+//
+// After the claim has been deleted it verifies that the composite and all
+// composed resources recorded before the deletion are gone as well. Use
+// WaitAllowRemainingByCompositionResourceName for resources that are expected
+// to remain.
 func DeleteClaim(claim client.Object, timeout time.Duration, waitOpts ...WaitOption) features.Func {
 	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
 		kube := cfg.Client()
 		kubeClient := kube.Resources().GetControllerRuntimeClient()
 
 		// Set the namespace to the default test namespace if not already set
-		if claim.GetNamespace() == "" {
-			isObjectNamespaced, err := kubeClient.IsObjectNamespaced(claim)
-			if err != nil {
-				t.Fatal(errors.Wrap(err, "cannot determine object scope").Error())
-			}
-			if isObjectNamespaced {
-				claim.SetNamespace(cfg.Namespace())
-			}
+		if err := prepareObjects(kube, cfg.Namespace(), claim); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		// Record the resource tree to detect leftovers once the claim is gone
+		snapshot, err := snapshotResourceTree(ctx, kube, claim)
+		if err != nil {
+			t.Errorf("cannot record resource tree, skipping check for leftover resources: %s\n", err.Error())
 		}
 
 		deleteCtx, cancel := contextWithOptionalTimeout(ctx, timeout)
@@ -49,74 +58,133 @@ func DeleteClaim(claim client.Object, timeout time.Duration, waitOpts ...WaitOpt
 		// one deleted after all resources have been deleted
 		if err := kubeClient.Delete(deleteCtx, claim, deleteForeground()); err != nil {
 			t.Errorf("failed to delete resource: %s\n", err.Error())
-
-			claim, composite, composed, err := collectResourceTree(ctx, kube, claim)
-			if err != nil {
-				t.Errorf("cannot collect undeleted resources: %s\n", err.Error())
-			} else {
-				t.Errorf("undeleted resources:\n%s\n", prettyPrintObjects(combineObjectsToSlice(claim, composite, composed), nil))
-			}
+			reportUndeletedResources(ctx, t, kube, claim)
 		}
 
-		waitCfg := WaitConfig{
-			waitForOptions: []wait.Option{wait.WithTimeout(timeout)},
-		}
+		waitCfg := WaitConfig{}
 		waitCfg.Apply(waitOpts)
 
-		if err := wait.For(isClaimDeleted(cfg.Client(), claim), waitCfg.waitForOptions...); err != nil {
+		var lastErr error
+		if err := wait.For(isClaimDeleted(kube, claim, &lastErr), waitCfg.waitForOptionsWithContext(deleteCtx)...); err != nil {
 			t.Errorf("failed waiting for resources to become deleted: %s\n", err.Error())
+			if lastErr != nil {
+				t.Errorf("cannot verify that the claim has been deleted: %s\n", lastErr.Error())
+				return ctx
+			}
+			reportUndeletedResources(ctx, t, kube, claim)
+			return ctx
 		}
+		// the leftover check shares the deadline of the deletion
+		checkLeftoverResources(ctx, deleteCtx, t, kube, snapshot, &waitCfg)
 		return ctx
 	}
 }
 
+// checkLeftoverResources waits until all objects of snapshot are deleted or
+// waitCtx is done and reports any object that is left over.
+func checkLeftoverResources(ctx, waitCtx context.Context, t *testing.T, kube klient.Client, snapshot []snapshotObject, waitCfg *WaitConfig) {
+	if len(snapshot) == 0 {
+		return
+	}
+	// the result is ignored because leftovers are reported below
+	_ = wait.For(areSnapshotObjectsDeleted(kube, snapshot, waitCfg), waitCfg.waitForOptionsWithContext(waitCtx)...)
+	leftovers, err := describeLeftovers(ctx, kube, snapshot, waitCfg)
+	if err != nil {
+		t.Errorf("cannot check for leftover resources: %s\n", err.Error())
+		return
+	}
+	if leftovers != "" {
+		t.Errorf("resources left over after deleting the claim:\n%s", leftovers)
+	}
+}
+
+// DeleteClaims deletes the given claim objects and waits until the objects
+// and all subresources have been deleted. Namespaced claims without a
+// namespace are deleted in the default test namespace. It does not cancel if
+// the passed timeout duration is zero.
 func DeleteClaims(claims []client.Object, timeout time.Duration, waitOpts ...WaitOption) features.Func {
 	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
-		kube := cfg.Client()
-
-		return deleteClaims(ctx, t, kube, claims, timeout, waitOpts...)
+		return deleteClaims(ctx, t, cfg, cfg.Client(), claims, timeout, waitOpts...)
 	}
 }
 
+// DeleteClaimsWithClient is like DeleteClaims but uses the provided client.
 func DeleteClaimsWithClient(claims []client.Object, kube klient.Client, timeout time.Duration, waitOpts ...WaitOption) features.Func {
 	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
-		return deleteClaims(ctx, t, kube, claims, timeout, waitOpts...)
+		return deleteClaims(ctx, t, cfg, kube, claims, timeout, waitOpts...)
 	}
 }
 
-func deleteClaims(ctx context.Context, t *testing.T, kube klient.Client, claims []client.Object, timeout time.Duration, waitOpts ...WaitOption) context.Context {
+func deleteClaims(ctx context.Context, t *testing.T, cfg *envconf.Config, kube klient.Client, claims []client.Object, timeout time.Duration, waitOpts ...WaitOption) context.Context {
 	kubeclient := kube.Resources().GetControllerRuntimeClient()
+
+	// Set the namespace to the default test namespace if not already set
+	if err := prepareObjects(kube, cfg.Namespace(), claims...); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	waitCfg := WaitConfig{}
+	waitCfg.Apply(waitOpts)
+
 	deleteCtx, cancel := contextWithOptionalTimeout(ctx, timeout)
 	defer cancel()
 
-	for _, claim := range claims {
-		go func() {
-			// Crossplane claims use cascading delete so the claim object will be the last
-			// one deleted after all resources have been deleted
-			if err := kubeclient.Delete(deleteCtx, claim, deleteForeground()); err != nil {
-				t.Errorf("failed to delete resource: %s\n", err.Error())
-
-				claim, composite, composed, errs := collectResourceTree(ctx, kube, claim)
-				if errs != nil {
-					t.Errorf("cannot collect undeleted resources: %s\n", errs.Error())
-				} else {
-					t.Errorf("undeleted resources:\n%s\n", prettyPrintObjects(combineObjectsToSlice(claim, composite, composed), nil))
-				}
-			}
-		}()
+	// Crossplane claims use cascading delete so the claim object will be the last
+	// one deleted after all resources have been deleted
+	errs := forEachClaimConcurrently(deleteCtx, claims, waitCfg.concurrency, func(ctx context.Context, _ int, claim client.Object) error {
+		return kubeclient.Delete(ctx, claim, deleteForeground())
+	})
+	failed := &bytes.Buffer{}
+	for i, err := range errs {
+		if err != nil {
+			fmt.Fprintf(failed, "  %s: %s\n", describeClaim(claims[i]), err.Error())
+		}
 	}
-
-	waitCfg := WaitConfig{
-		waitForOptions: []wait.Option{wait.WithTimeout(timeout)},
+	if failed.Len() > 0 {
+		t.Errorf("failed to delete claims:\n%s", failed.String())
 	}
-	waitCfg.Apply(waitOpts)
 
-	if err := wait.For(areClaimsDeleted(kube, claims), waitCfg.waitForOptions...); err != nil {
+	var lastErr error
+	if err := wait.For(areClaimsDeleted(kube, claims, &lastErr), waitCfg.waitForOptionsWithContext(deleteCtx)...); err != nil {
 		t.Errorf("failed waiting for resources to become deleted: %s\n", err.Error())
+		if lastErr != nil {
+			t.Errorf("cannot verify that the claims have been deleted: %s\n", lastErr.Error())
+		}
+		present := presentClaims(ctx, kube, claims)
+		names := make([]string, len(present))
+		for i, claim := range present {
+			names[i] = describeClaim(claim)
+		}
+		t.Errorf("claims still present: %s\n", strings.Join(names, ", "))
+		for _, claim := range present {
+			reportUndeletedResources(ctx, t, kube, claim)
+		}
 	}
 	return ctx
 }
 
+func describeClaim(claim client.Object) string {
+	return fmt.Sprintf("%s %s", claim.GetObjectKind().GroupVersionKind().Kind, client.ObjectKeyFromObject(claim).String())
+}
+
+// reportUndeletedResources collects the remaining resource tree of claim and
+// reports the finalizers, owners and usages that block the deletion of each
+// object.
+func reportUndeletedResources(ctx context.Context, t *testing.T, kube klient.Client, claim client.Object) {
+	claimOnCluster, composite, composed, err := collectResourceTree(ctx, kube, claim)
+	if err != nil {
+		t.Errorf("cannot collect undeleted resources: %s\n", err.Error())
+		return
+	}
+	report, err := newDeletionReport(ctx, kube, combineObjectsToSlice(claimOnCluster, composite, composed))
+	if err != nil {
+		t.Errorf("cannot list usages: %s\n", err.Error())
+	}
+	if len(report.Rows) > 0 {
+		t.Errorf("undeleted resources:\n%s\n", report.String())
+	}
+}
+
 func deleteForeground() client.DeleteOption {
 	return &client.DeleteOptions{
 		PropagationPolicy: ptr.To(metav1.DeletePropagationForeground),
@@ -130,24 +198,54 @@ func contextWithOptionalTimeout(parentCtx context.Context, timeout time.Duration
 	return context.WithTimeout(parentCtx, timeout)
 }
 
-func isClaimDeleted(kube klient.Client, sourceClaim client.Object) apimachinerywait.ConditionWithContextFunc {
+// isClaimDeleted returns true once the claim is not found on the cluster.
+// Other errors are stored in lastErr and retried, as they do not prove that
+// the claim has been deleted.
+func isClaimDeleted(kube klient.Client, sourceClaim client.Object, lastErr *error) apimachinerywait.ConditionWithContextFunc {
 	return func(ctx context.Context) (bool, error) {
-		claimOnCluster := xpclaim.New(xpclaim.WithGroupVersionKind(sourceClaim.GetObjectKind().GroupVersionKind()))
-		if err := klient.Get(ctx, kube, sourceClaim.GetName(), sourceClaim.GetNamespace(), claimOnCluster); err == nil {
-			return false, nil
-		}
-		return true, nil
+		exists, err := claimExists(ctx, kube, sourceClaim)
+		*lastErr = err
+		return err == nil && !exists, nil
 	}
 }
 
-func areClaimsDeleted(kube klient.Client, sourceClaims []client.Object) apimachinerywait.ConditionWithContextFunc {
+// areClaimsDeleted returns true once none of the claims are found on the
+// cluster. Other errors are stored in lastErr and retried.
+func areClaimsDeleted(kube klient.Client, sourceClaims []client.Object, lastErr *error) apimachinerywait.ConditionWithContextFunc {
 	return func(ctx context.Context) (bool, error) {
 		for _, sourceClaim := range sourceClaims {
-			claimOnCluster := xpclaim.New(xpclaim.WithGroupVersionKind(sourceClaim.GetObjectKind().GroupVersionKind()))
-			if err := klient.Get(ctx, kube, sourceClaim.GetName(), sourceClaim.GetNamespace(), claimOnCluster); err == nil {
+			exists, err := claimExists(ctx, kube, sourceClaim)
+			*lastErr = err
+			if err != nil || exists {
 				return false, nil
 			}
 		}
 		return true, nil
 	}
 }
+
+// claimExists returns false if the claim is not found on the cluster. Any
+// other error is returned.
+func claimExists(ctx context.Context, kube klient.Client, sourceClaim client.Object) (bool, error) {
+	claimOnCluster := xpclaim.New(xpclaim.WithGroupVersionKind(sourceClaim.GetObjectKind().GroupVersionKind()))
+	err := kube.Resources().GetControllerRuntimeClient().Get(ctx, client.ObjectKeyFromObject(sourceClaim), claimOnCluster)
+	if kerrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "cannot get %s", describeClaim(sourceClaim))
+	}
+	return true, nil
+}
+
+// presentClaims returns all claims that still exist on the cluster or whose
+// existence cannot be determined.
+func presentClaims(ctx context.Context, kube klient.Client, sourceClaims []client.Object) []client.Object {
+	var present []client.Object
+	for _, sourceClaim := range sourceClaims {
+		if exists, err := claimExists(ctx, kube, sourceClaim); exists || err != nil {
+			present = append(present, sourceClaim)
+		}
+	}
+	return present
+}
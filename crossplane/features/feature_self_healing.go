@@ -0,0 +1,122 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package features
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/crossplane/resources"
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/klient"
+)
+
+// fieldObservation is a value of a field observed at a point in time
+// relative to the start of a wait operation.
+type fieldObservation struct {
+	after time.Duration
+	value string
+}
+
+// AssessSelfHealing is a feature that sets the field at path of the composed
+// resource of claim with the given composition resource name to driftValue
+// and waits until the desired value is restored by crossplane or the provider.
+// The desired value is the value of the field before it is modified.
+//
+// If the field is not restored in time, the observed values of the field are
+// reported. It does not cancel if the passed timeout duration is zero.
+func AssessSelfHealing(claim client.Object, compositionResourceName string, composedGVK schema.GroupVersionKind, path string, driftValue any, timeout time.Duration, waitOpts ...WaitOption) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		kube := cfg.Client()
+
+		// Set the namespace to the default test namespace if not already set
+		if err := prepareObjects(kube, cfg.Namespace(), claim); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		desired, err := driftComposedField(ctx, kube, claim, compositionResourceName, composedGVK, path, driftValue)
+		if err != nil {
+			t.Errorf("cannot modify composed resource %q: %s\n", compositionResourceName, err.Error())
+			return ctx
+		}
+		wantValue, err := normalizeJSON(desired)
+		if err != nil {
+			t.Errorf("cannot normalize desired value: %s\n", err.Error())
+			return ctx
+		}
+
+		waitCtx, cancel := contextWithOptionalTimeout(ctx, timeout)
+		defer cancel()
+		waitCfg := WaitConfig{}
+		waitCfg.Apply(waitOpts)
+
+		start := time.Now()
+		var observations []fieldObservation
+		err = wait.For(func(ctx context.Context) (bool, error) {
+			composed := &unstructured.Unstructured{}
+			composed.SetGroupVersionKind(composedGVK)
+			if err := resources.GetComposedFromClaim(ctx, kube, claim, compositionResourceName, composed); err != nil {
+				return false, nil //nolint:nilerr // retry until the resource is available again
+			}
+			value, err := fieldpath.Pave(composed.Object).GetValue(path)
+			observed := "<not set>"
+			if err == nil {
+				observed = formatFieldValue(value)
+			}
+			if len(observations) == 0 || observations[len(observations)-1].value != observed {
+				observations = append(observations, fieldObservation{after: time.Since(start), value: observed})
+			}
+			if err != nil {
+				return false, nil //nolint:nilerr // field has been removed, wait until it is restored
+			}
+			got, err := normalizeJSON(value)
+			return err == nil && reflect.DeepEqual(got, wantValue), nil
+		}, waitCfg.waitForOptionsWithContext(waitCtx)...)
+		if err != nil {
+			buf := &bytes.Buffer{}
+			for _, o := range observations {
+				fmt.Fprintf(buf, "  +%s\t%s\n", o.after.Round(time.Millisecond), o.value)
+			}
+			t.Errorf("field %q of composed resource %q was not restored to %s: %s\nobserved values:\n%s", path, compositionResourceName, formatFieldValue(desired), err.Error(), buf.String())
+		}
+		return ctx
+	}
+}
+
+// driftComposedField sets the field at path of a composed resource to value
+// and returns the previous value.
+func driftComposedField(ctx context.Context, kube klient.Client, claim client.Object, compositionResourceName string, composedGVK schema.GroupVersionKind, path string, value any) (any, error) {
+	var desired any
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		composed := &unstructured.Unstructured{}
+		composed.SetGroupVersionKind(composedGVK)
+		if err := resources.GetComposedFromClaim(ctx, kube, claim, compositionResourceName, composed); err != nil {
+			return errors.Wrap(err, "cannot get composed resource")
+		}
+		paved := fieldpath.Pave(composed.Object)
+		current, err := paved.GetValue(path)
+		if err != nil {
+			return errors.Wrapf(err, "cannot get desired value of field %q", path)
+		}
+		desired = current
+		if err := paved.SetValue(path, value); err != nil {
+			return errors.Wrapf(err, "cannot set field %q", path)
+		}
+		return kube.Resources().GetControllerRuntimeClient().Update(ctx, composed)
+	})
+	return desired, err
+}
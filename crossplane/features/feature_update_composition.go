@@ -0,0 +1,209 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package features
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apimachinerywait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/crossplane/internal/meta"
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/defaults"
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/klient"
+)
+
+var (
+	compositionGVK             = schema.GroupVersionKind{Group: "apiextensions.crossplane.io", Version: "v1", Kind: "Composition"}
+	compositionRevisionListGVK = schema.GroupVersionKind{Group: "apiextensions.crossplane.io", Version: "v1", Kind: "CompositionRevisionList"}
+)
+
+// UpdateComposition is a feature that updates the composition used by the
+// composite of claim and waits until the composite has converged again.
+//
+// mutate is applied to the current composition before it is updated. It must
+// change the spec of the composition. The feature then waits until crossplane
+// created a new CompositionRevision. If the composite uses the Automatic
+// update policy it waits until the composite references the new revision and
+// has been reconciled again, so the readiness of the tree reflects the new
+// revision. With the Manual update policy it verifies that the composite stays
+// pinned to its previous revision. Finally it waits until the claim tree is
+// ready in the same way WaitForClaimReady does.
+//
+// All steps share the passed timeout. It does not cancel if the passed timeout
+// duration is zero.
+func UpdateComposition(claim client.Object, mutate func(composition *unstructured.Unstructured), timeout time.Duration, waitOpts ...WaitOption) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		kube := cfg.Client()
+
+		// Set the namespace to the default test namespace if not already set
+		if err := prepareObjects(kube, cfg.Namespace(), claim); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		_, composite, _, err := collectResourceTree(ctx, kube, claim)
+		if err != nil {
+			t.Fatal(errors.Wrap(err, "cannot collect resource tree").Error())
+		}
+		if composite == nil || composite.GetCompositionReference() == nil {
+			t.Fatal("claim does not reference a composite with a composition")
+		}
+		compositionName := composite.GetCompositionReference().Name
+		previousRevision := ""
+		if ref := composite.GetCompositionRevisionReference(); ref != nil {
+			previousRevision = ref.Name
+		}
+		policy := composite.GetCompositionUpdatePolicy()
+		pinned := policy != nil && *policy == xpv1.UpdateManual
+		if pinned && previousRevision == "" {
+			t.Fatal("composite with manual update policy does not reference a composition revision yet")
+		}
+
+		_, latestRevision, err := getLatestCompositionRevision(ctx, kube, compositionName)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if err := updateComposition(ctx, kube, compositionName, mutate); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		waitCtx, cancel := contextWithOptionalTimeout(ctx, timeout)
+		defer cancel()
+		waitCfg := WaitConfig{}
+		waitCfg.Apply(waitOpts)
+		waitForOptions := waitCfg.waitForOptionsWithContext(waitCtx)
+
+		newRevision := ""
+		if err := wait.For(func(ctx context.Context) (bool, error) {
+			name, revision, err := getLatestCompositionRevision(ctx, kube, compositionName)
+			if err != nil {
+				return false, err
+			}
+			newRevision = name
+			return revision > latestRevision, nil
+		}, waitForOptions...); err != nil {
+			t.Errorf("failed waiting for a new revision of composition %q: %s\n", compositionName, err.Error())
+			return ctx
+		}
+
+		expectedRevision := newRevision
+		if pinned {
+			expectedRevision = previousRevision
+		}
+		if err := wait.For(isCompositeAtRevision(kube, claim, expectedRevision), waitForOptions...); err != nil {
+			t.Errorf("failed waiting for composite to reference composition revision %q: %s\n", expectedRevision, err.Error())
+			return ctx
+		}
+		if !pinned {
+			// The conditions of the tree are still those of the previous
+			// revision until the composite has been reconciled again.
+			var observed string
+			if err := wait.For(isCompositeReconciled(kube, claim, &observed), waitForOptions...); err != nil {
+				t.Errorf("failed waiting for composite to be reconciled with composition revision %q: %s\nlast observed: %s\n", expectedRevision, err.Error(), observed)
+				return ctx
+			}
+		}
+
+		if waitCfg.kindsWithoutConditions == nil {
+			waitCfg.kindsWithoutConditions = KindRegistryFromContext(ctx)
+		}
+		if err := waitForClaimReady(waitCtx, t, kube, claim, &waitCfg); err != nil {
+			t.Errorf("failed waiting for resources to become ready: %s\n", err.Error())
+			reportResourceTree(ctx, t, kube, claim, &waitCfg)
+			return ctx
+		}
+
+		if pinned {
+			// the composite must not have been moved while it converged
+			if ok, err := isCompositeAtRevision(kube, claim, expectedRevision)(ctx); err != nil || !ok {
+				t.Errorf("composite with manual update policy is not pinned to composition revision %q anymore\n", expectedRevision)
+			}
+		}
+		return ctx
+	}
+}
+
+func updateComposition(ctx context.Context, kube klient.Client, name string, mutate func(composition *unstructured.Unstructured)) error {
+	kubeClient := kube.Resources().GetControllerRuntimeClient()
+	return errors.Wrapf(retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		composition := &unstructured.Unstructured{}
+		composition.SetGroupVersionKind(compositionGVK)
+		if err := klient.Get(ctx, kube, name, "", composition); err != nil {
+			return err
+		}
+		previous := composition.DeepCopy()
+		mutate(composition)
+		if equality.Semantic.DeepEqual(previous.Object["spec"], composition.Object["spec"]) {
+			return errors.New("mutate did not change the spec of the composition, no new revision would be created")
+		}
+		return kubeClient.Update(ctx, composition)
+	}), "cannot update composition %q", name)
+}
+
+// getLatestCompositionRevision returns the name and revision number of the
+// latest CompositionRevision of a composition. It returns an empty name if
+// there is no revision.
+func getLatestCompositionRevision(ctx context.Context, kube klient.Client, compositionName string) (string, int64, error) {
+	revisions := &unstructured.UnstructuredList{}
+	revisions.SetGroupVersionKind(compositionRevisionListGVK)
+	if err := retry.OnError(defaults.DefaultBackoff, func(error) bool { return true }, func() error {
+		return kube.Resources().GetControllerRuntimeClient().List(ctx, revisions, client.MatchingLabels{meta.LabelKeyCompositionName: compositionName})
+	}); err != nil {
+		return "", 0, errors.Wrapf(err, "cannot list revisions of composition %q", compositionName)
+	}
+	latestName := ""
+	latest := int64(0)
+	for _, r := range revisions.Items {
+		revision, _, _ := unstructured.NestedInt64(r.Object, "spec", "revision")
+		if revision > latest {
+			latestName = r.GetName()
+			latest = revision
+		}
+	}
+	return latestName, latest, nil
+}
+
+// isCompositeReconciled returns true once crossplane has observed the current
+// generation of the composite of sourceClaim. The observed and current
+// generation are stored in observed.
+func isCompositeReconciled(kube klient.Client, sourceClaim client.Object, observed *string) apimachinerywait.ConditionWithContextFunc {
+	return func(ctx context.Context) (bool, error) {
+		_, composite, _, err := collectResourceTree(ctx, kube, sourceClaim)
+		if err != nil {
+			return false, err
+		}
+		if composite == nil {
+			return false, nil
+		}
+		observedGeneration := max(composite.GetObservedGeneration(), composite.GetCondition(xpv1.TypeSynced).ObservedGeneration)
+		*observed = fmt.Sprintf("observed generation %d, generation %d", observedGeneration, composite.GetGeneration())
+		return observedGeneration >= composite.GetGeneration(), nil
+	}
+}
+
+func isCompositeAtRevision(kube klient.Client, sourceClaim client.Object, revisionName string) apimachinerywait.ConditionWithContextFunc {
+	return func(ctx context.Context) (bool, error) {
+		_, composite, _, err := collectResourceTree(ctx, kube, sourceClaim)
+		if err != nil {
+			return false, err
+		}
+		if composite == nil {
+			return false, nil
+		}
+		ref := composite.GetCompositionRevisionReference()
+		return ref != nil && ref.Name == revisionName, nil
+	}
+}
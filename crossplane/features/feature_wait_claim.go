@@ -5,68 +5,193 @@ package features
 
 import (
 	"context"
-	"slices"
 	"testing"
 	"time"
 
+	xpclaim "github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	xpcomposed "github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composed"
+	xpcomposite "github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
 	apimachinerywait "k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/e2e-framework/klient/wait"
 	"sigs.k8s.io/e2e-framework/pkg/envconf"
 	"sigs.k8s.io/e2e-framework/pkg/features"
 
-	"github.com/dbinfrago/kubernetes-e2e-test-framework/crossplane/internal/meta"
 	"github.com/dbinfrago/kubernetes-e2e-test-framework/klient"
 )
 
 // WaitForClaimReady is a feature that waits until the claim, composite and
-// all composed resources have the conditions "Synced" and "Ready".
+// all composed resources have the conditions "Synced" and "Ready". Resources of
+// nested composites are checked as well.
+//
+// The resource tree is polled by default. Use WaitWithWatch to re-evaluate the
+// tree on watch events instead. It does not cancel if the passed timeout
+// duration is zero.
+//
+// The time until each object of the tree was first observed Synced and Ready
+// is recorded in the returned context, see ReadinessTimingsFromContext and
+// WaitWithTimingsExport.
 func WaitForClaimReady(claim client.Object, timeout time.Duration, waitOpts ...WaitOption) features.Func {
 	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
 		kube := cfg.Client()
 
-		waitCfg := WaitConfig{
-			waitForOptions: []wait.Option{wait.WithTimeout(timeout)},
+		// Set the namespace to the default test namespace if not already set
+		if err := prepareObjects(kube, cfg.Namespace(), claim); err != nil {
+			t.Fatal(err.Error())
 		}
+
+		waitCfg := WaitConfig{}
 		waitCfg.Apply(waitOpts)
+		if waitCfg.kindsWithoutConditions == nil {
+			waitCfg.kindsWithoutConditions = KindRegistryFromContext(ctx)
+		}
+
+		ctx, timings := contextWithReadinessTimings(ctx)
+		waitCfg.timingObserver = newTimingObserver(timings, claim, time.Now())
 
-		if err := wait.For(waitForConditionReadyAndSynced(kube, claim, waitCfg.ignoreComposedByCompositionResourceName), waitCfg.waitForOptions...); err != nil {
+		waitCtx, cancel := contextWithOptionalTimeout(ctx, timeout)
+		defer cancel()
+
+		if err := waitForClaimReady(waitCtx, t, kube, claim, &waitCfg); err != nil {
 			t.Errorf("failed waiting for resources to become ready: %s\n", err.Error())
+			reportResourceTree(ctx, t, kube, claim, &waitCfg)
+		}
+		exportReadinessTimings(t, timings, &waitCfg)
+		return ctx
+	}
+}
+
+// WaitForClaimsReady is a feature that waits until the resource trees of all
+// claims are synced and ready. The trees are evaluated concurrently, limited
+// by WaitWithConcurrency, and share a single deadline.
+//
+// A report lists for every claim whether it converged and how long it took.
+// It does not cancel if the passed timeout duration is zero.
+func WaitForClaimsReady(claims []client.Object, timeout time.Duration, waitOpts ...WaitOption) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		kube := cfg.Client()
 
-			// collect the resource tree and output the YAML of every unready
-			// resource
-			claim, composite, composed, err := collectResourceTree(ctx, kube, claim)
-			if err != nil {
-				t.Errorf("cannot collect unready resources: %s\n", err.Error())
-			} else {
-				t.Errorf("unready resources:\n%s\n", prettyPrintObjects(combineObjectsToSlice(claim, composite, composed), skipReadyAndSynced))
+		// Set the namespace to the default test namespace if not already set
+		if err := prepareObjects(kube, cfg.Namespace(), claims...); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		waitCfg := WaitConfig{}
+		waitCfg.Apply(waitOpts)
+		if waitCfg.kindsWithoutConditions == nil {
+			waitCfg.kindsWithoutConditions = KindRegistryFromContext(ctx)
+		}
+
+		ctx, timings := contextWithReadinessTimings(ctx)
+		defer exportReadinessTimings(t, timings, &waitCfg)
+
+		waitCtx, cancel := contextWithOptionalTimeout(ctx, timeout)
+		defer cancel()
+
+		start := time.Now()
+		durations := make([]time.Duration, len(claims))
+		errs := forEachClaimConcurrently(waitCtx, claims, waitCfg.concurrency, func(ctx context.Context, i int, claim client.Object) error {
+			claimCfg := waitCfg
+			claimCfg.timingObserver = newTimingObserver(timings, claim, start)
+			err := waitForClaimReady(ctx, t, kube, claim, &claimCfg)
+			durations[i] = time.Since(start)
+			return err
+		})
+
+		report := &ClaimsReport{}
+		for i, claim := range claims {
+			report.Rows = append(report.Rows, ClaimsReportRow{
+				GroupVersionKind: claim.GetObjectKind().GroupVersionKind(),
+				Namespace:        claim.GetNamespace(),
+				Name:             claim.GetName(),
+				Ready:            errs[i] == nil,
+				Duration:         durations[i],
+				Err:              errs[i],
+			})
+		}
+		if !report.IsReady() {
+			t.Errorf("failed waiting for claims to become ready:\n%s\n", report.String())
+			for i, claim := range claims {
+				if errs[i] != nil {
+					reportResourceTree(ctx, t, kube, claim, &waitCfg)
+				}
 			}
+			return ctx
 		}
+		t.Logf("claims ready:\n%s\n", report.String())
 		return ctx
 	}
 }
 
-func waitForConditionReadyAndSynced(kube klient.Client, sourceClaim client.Object, ignoreComposedByCompositionResourceName []string) apimachinerywait.ConditionWithContextFunc {
+// exportReadinessTimings writes timings to the file configured with
+// WaitWithTimingsExport.
+func exportReadinessTimings(t *testing.T, timings *ReadinessTimings, waitCfg *WaitConfig) {
+	if waitCfg.timingsExportPath == "" {
+		return
+	}
+	if err := timings.writeFile(waitCfg.timingsExportPath); err != nil {
+		t.Errorf("cannot export readiness timings: %s\n", err.Error())
+	}
+}
+
+// waitForClaimReady waits until the resource tree of claim is synced and
+// ready or ctx is done. It falls back to polling if the tree cannot be
+// watched.
+func waitForClaimReady(ctx context.Context, t *testing.T, kube klient.Client, claim client.Object, waitCfg *WaitConfig) error {
+	var err error
+	if waitCfg.watch {
+		err = waitForClaimReadyWithWatch(ctx, kube, claim, waitCfg)
+	}
+	if !waitCfg.watch || isWatchUnavailable(err) {
+		if err != nil {
+			t.Logf("cannot watch resource tree, falling back to polling: %s\n", err.Error())
+		}
+		err = wait.For(waitForConditionReadyAndSynced(kube, claim, waitCfg), waitCfg.waitForOptionsWithContext(ctx)...)
+	}
+	return err
+}
+
+// reportResourceTree collects the resource tree of claim and outputs a report
+// of every resource.
+func reportResourceTree(ctx context.Context, t *testing.T, kube klient.Client, claim client.Object, waitCfg *WaitConfig) {
+	claimOnCluster, composite, composed, err := collectResourceTree(ctx, kube, claim)
+	if err != nil {
+		t.Errorf("cannot collect unready resources: %s\n", err.Error())
+		return
+	}
+	report := newTreeReport(claimOnCluster, composite, composed, waitCfg)
+	t.Errorf("resource tree:\n%s\n", report.String())
+	if waitCfg.printYAML {
+		t.Errorf("unready resources:\n%s\n", report.YAML())
+	}
+}
+
+func waitForConditionReadyAndSynced(kube klient.Client, sourceClaim client.Object, waitCfg *WaitConfig) apimachinerywait.ConditionWithContextFunc {
 	return func(ctx context.Context) (bool, error) {
 		claim, composite, composed, err := collectResourceTree(ctx, kube, sourceClaim)
 		if err != nil {
 			return false, err
 		}
-		// composite is nil if wait for MR, but is not nil anymore in isObjectSyncedAndReady due to interface cast
-		if !isObjectSyncedAndReady(claim) || (composite != nil && !isObjectSyncedAndReady(composite)) {
-			return false, nil
+		waitCfg.observeTree(claim, composite, composed)
+		return isResourceTreeSyncedAndReady(claim, composite, composed, waitCfg), nil
+	}
+}
+
+func isResourceTreeSyncedAndReady(claim *xpclaim.Unstructured, composite *xpcomposite.Unstructured, composed []*xpcomposed.Unstructured, waitCfg *WaitConfig) bool {
+	// composite is nil if wait for MR, but is not nil anymore in isObjectReady due to interface cast
+	if !waitCfg.isObjectReady(claim) || (composite != nil && !waitCfg.isObjectReady(composite)) {
+		return false
+	}
+	for _, o := range composed {
+		if o == nil {
+			continue
+		}
+		if waitCfg.isIgnored(o) {
+			continue // skip checks for resources that are explicitly ignored
 		}
-		for _, o := range composed {
-			if o == nil {
-				continue
-			}
-			if slices.Contains(ignoreComposedByCompositionResourceName, meta.GetCompositionResourceName(o)) {
-				continue // skip checks for resources that are explicitly ignored
-			}
-			if !isObjectSyncedAndReady(o) {
-				return false, nil
-			}
+		if !waitCfg.isObjectReady(o) {
+			return false
 		}
-		return true, nil
 	}
+	return true
 }
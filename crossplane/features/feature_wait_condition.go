@@ -0,0 +1,168 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package features
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+)
+
+// ConditionExpectation describes the expected state of a status condition.
+// Empty fields match any value.
+type ConditionExpectation struct {
+	Type    xpv1.ConditionType
+	Status  corev1.ConditionStatus
+	Reason  xpv1.ConditionReason
+	Message *regexp.Regexp
+}
+
+func (e ConditionExpectation) String() string {
+	parts := []string{"type=" + string(e.Type)}
+	if e.Status != "" {
+		parts = append(parts, "status="+string(e.Status))
+	}
+	if e.Reason != "" {
+		parts = append(parts, "reason="+string(e.Reason))
+	}
+	if e.Message != nil {
+		parts = append(parts, fmt.Sprintf("message=~%q", e.Message.String()))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (e ConditionExpectation) matches(c xpv1.Condition) bool {
+	return (e.Status == "" || c.Status == e.Status) &&
+		(e.Reason == "" || c.Reason == e.Reason) &&
+		(e.Message == nil || e.Message.MatchString(c.Message))
+}
+
+// WaitForClaimCondition is a feature that waits until the condition of the
+// targeted object of the claim resource tree matches expected. Use OnClaim,
+// OnComposite or OnComposed to select the object.
+//
+// If the condition does not match in time, the last observed condition is
+// reported. It does not cancel if the passed timeout duration is zero.
+func WaitForClaimCondition(claim client.Object, target TreeTarget, expected ConditionExpectation, timeout time.Duration, waitOpts ...WaitOption) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		kube := cfg.Client()
+
+		// Set the namespace to the default test namespace if not already set
+		if err := prepareObjects(kube, cfg.Namespace(), claim); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		waitCtx, cancel := contextWithOptionalTimeout(ctx, timeout)
+		defer cancel()
+		waitCfg := WaitConfig{}
+		waitCfg.Apply(waitOpts)
+
+		var last string
+		err := wait.For(func(ctx context.Context) (bool, error) {
+			o, err := target.get(ctx, kube, claim)
+			if err != nil {
+				last = err.Error()
+				return false, nil
+			}
+			c, ok := getCondition(o, expected.Type)
+			if !ok {
+				last = fmt.Sprintf("no condition of type %s", expected.Type)
+				return false, nil
+			}
+			last = formatCondition(c)
+			return expected.matches(c), nil
+		}, waitCfg.waitForOptionsWithContext(waitCtx)...)
+		if err != nil {
+			t.Errorf("condition of %s does not match %s: %s\nlast observed: %s\n", target, expected, err.Error(), last)
+		}
+		return ctx
+	}
+}
+
+// AssertClaimNeverReady is a feature that verifies that the claim does not
+// become Ready within window. It is meant for claims that are expected to
+// fail, e.g. because of an invalid parameter or an exceeded quota. It fails if
+// the claim cannot be fetched at all within window.
+func AssertClaimNeverReady(claim client.Object, window time.Duration, waitOpts ...WaitOption) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		kube := cfg.Client()
+
+		// Set the namespace to the default test namespace if not already set
+		if err := prepareObjects(kube, cfg.Namespace(), claim); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		waitCtx, cancel := context.WithTimeout(ctx, window)
+		defer cancel()
+		waitCfg := WaitConfig{}
+		waitCfg.Apply(waitOpts)
+
+		start := time.Now()
+		var ready *xpv1.Condition
+		fetched := false
+		var lastErr error
+		check := func(ctx context.Context) (bool, error) {
+			o, err := OnClaim().get(ctx, kube, claim)
+			if err != nil {
+				lastErr = err
+				return false, nil //nolint:nilerr // retry until the window has passed
+			}
+			fetched = true
+			if c, ok := getCondition(o, xpv1.TypeReady); ok && c.Status == corev1.ConditionTrue {
+				ready = &c
+				return true, nil
+			}
+			return false, nil
+		}
+		// the wait only succeeds if the claim became ready, a timeout is the
+		// expected outcome
+		_ = wait.For(check, append(waitCfg.waitForOptionsWithContext(waitCtx), wait.WithImmediate())...)
+		// the interval may end before the window does, so check once more at
+		// the end of the window
+		if ready == nil {
+			_, _ = check(ctx)
+		}
+		if !fetched {
+			if lastErr != nil {
+				t.Errorf("claim could not be fetched within %s: %s\n", window, lastErr)
+			} else {
+				t.Errorf("claim could not be fetched within %s\n", window)
+			}
+			return ctx
+		}
+		if ready != nil {
+			t.Errorf("claim became ready after %s although it was expected to fail: %s\n", time.Since(start).Round(time.Second), formatCondition(*ready))
+		}
+		return ctx
+	}
+}
+
+// getCondition returns the condition of type ct of o and whether o reports
+// such a condition at all.
+func getCondition(o *unstructured.Unstructured, ct xpv1.ConditionType) (xpv1.Condition, bool) {
+	conditioned := xpv1.ConditionedStatus{}
+	_ = fieldpath.Pave(o.Object).GetValueInto("status", &conditioned)
+	for _, c := range conditioned.Conditions {
+		if c.Type == ct {
+			return c, true
+		}
+	}
+	return xpv1.Condition{Type: ct}, false
+}
+
+func formatCondition(c xpv1.Condition) string {
+	return fmt.Sprintf("type=%s status=%s reason=%s message=%q", c.Type, c.Status, orDash(string(c.Reason)), c.Message)
+}
@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package features
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/crossplane/resources/connectiondetails"
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/klient"
+)
+
+// WaitForConnectionDetails is a feature that waits until claim references a
+// connection secret, the secret exists and contains all keys with non-empty
+// values.
+func WaitForConnectionDetails(claim client.Object, keys []string, timeout time.Duration, waitOpts ...WaitOption) features.Func {
+	return waitForConnectionDetails(claim, timeout, waitOpts, func(ctx context.Context, kube klient.Client) error {
+		_, err := connectiondetails.FetchFromClaimObject(ctx, kube, claim, connectiondetails.RequireKeys(keys...))
+		return err
+	})
+}
+
+// WaitForCompositeConnectionDetails is a feature that waits until composite
+// references a connection secret, the secret exists and contains all keys
+// with non-empty values.
+func WaitForCompositeConnectionDetails(composite client.Object, keys []string, timeout time.Duration, waitOpts ...WaitOption) features.Func {
+	return waitForConnectionDetails(composite, timeout, waitOpts, func(ctx context.Context, kube klient.Client) error {
+		_, err := connectiondetails.FetchFromCompositeObject(ctx, kube, composite, connectiondetails.RequireKeys(keys...))
+		return err
+	})
+}
+
+// WaitForComposedConnectionDetails is a feature that waits until the composed
+// resource of claim with the given composition resource name references a
+// connection secret, the secret exists and contains all keys with non-empty
+// values.
+func WaitForComposedConnectionDetails(claim client.Object, resourceName string, resourceGVK schema.GroupVersionKind, keys []string, timeout time.Duration, waitOpts ...WaitOption) features.Func {
+	return waitForConnectionDetails(claim, timeout, waitOpts, func(ctx context.Context, kube klient.Client) error {
+		_, err := connectiondetails.FetchFromComposedByClaim(ctx, kube, claim, resourceName, resourceGVK, connectiondetails.RequireKeys(keys...))
+		return err
+	})
+}
+
+// WaitForValidConnectionDetails is a feature that waits until the connection
+// secret of claim exists and satisfies all rules.
+func WaitForValidConnectionDetails(claim client.Object, rules []connectiondetails.Rule, timeout time.Duration, waitOpts ...WaitOption) features.Func {
+	return waitForConnectionDetails(claim, timeout, waitOpts, func(ctx context.Context, kube klient.Client) error {
+		_, err := connectiondetails.FetchFromClaimObject(ctx, kube, claim, rules...)
+		return err
+	})
+}
+
+// waitForConnectionDetails waits until fetch succeeds. A namespaced o without
+// a namespace is looked up in the default test namespace. It does not cancel
+// if the passed timeout duration is zero.
+func waitForConnectionDetails(o client.Object, timeout time.Duration, waitOpts []WaitOption, fetch func(ctx context.Context, kube klient.Client) error) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		kube := cfg.Client()
+
+		// Set the namespace to the default test namespace if not already set
+		if err := prepareObjects(kube, cfg.Namespace(), o); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		waitCtx, cancel := contextWithOptionalTimeout(ctx, timeout)
+		defer cancel()
+		waitCfg := WaitConfig{}
+		waitCfg.Apply(waitOpts)
+
+		// keep the error of the last attempt to report the missing step
+		var lastErr error
+		err := wait.For(func(ctx context.Context) (bool, error) {
+			lastErr = fetch(ctx, kube)
+			return lastErr == nil, nil
+		}, waitCfg.waitForOptionsWithContext(waitCtx)...)
+		if err != nil {
+			t.Errorf("failed waiting for connection details: %s\nlast attempt: %s\n", err.Error(), lastErr)
+		}
+		return ctx
+	}
+}
@@ -16,15 +16,15 @@ import (
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
 
 	"github.com/dbinfrago/kubernetes-e2e-test-framework/klient"
 )
 
-func hasObjectStatusConditions(o client.Object) bool {
-	_, onBlackList := groupKindsWithoutConditions[o.GetObjectKind().GroupVersionKind().GroupKind()]
-	return !onBlackList
+func hasObjectStatusConditions(o client.Object, kindsWithoutConditions *KindRegistry) bool {
+	return !kindsWithoutConditions.Has(o.GetObjectKind().GroupVersionKind().GroupKind())
 }
 
 type objectWithConditions interface {
@@ -32,11 +32,11 @@ type objectWithConditions interface {
 	xpresource.Conditioned
 }
 
-func isObjectSyncedAndReady(o objectWithConditions) bool {
+func isObjectSyncedAndReady(o objectWithConditions, kindsWithoutConditions *KindRegistry) bool {
 	if o == nil {
 		return false
 	}
-	if !hasObjectStatusConditions(o) {
+	if !hasObjectStatusConditions(o, kindsWithoutConditions) {
 		return true
 	}
 	if o.GetCondition(xpv1.TypeSynced).Status != corev1.ConditionTrue {
@@ -48,11 +48,12 @@ func isObjectSyncedAndReady(o objectWithConditions) bool {
 	return true
 }
 
-// collectResourceTree for the given claim
+// collectResourceTree for the given claim. Resources composed by nested
+// composites are included in the composed resources.
 func collectResourceTree(ctx context.Context, kube klient.Client, claim client.Object) (*xpclaim.Unstructured, *xpcomposite.Unstructured, []*xpcomposed.Unstructured, error) {
 	var claimOnCluster *xpclaim.Unstructured
 	var compositeOnCluster *xpcomposite.Unstructured
-	var composedOnCluster []*xpcomposed.Unstructured //nolint:prealloc
+	var composedOnCluster []*xpcomposed.Unstructured
 
 	// Retrieve the actual claim on the cluster
 	claimOnCluster = xpclaim.New(xpclaim.WithGroupVersionKind(claim.GetObjectKind().GroupVersionKind()))
@@ -72,25 +73,102 @@ func collectResourceTree(ctx context.Context, kube klient.Client, claim client.O
 
 	// Retrieve the state of the actual composed resources by specified in
 	// the composite
-	for _, ref := range compositeOnCluster.GetResourceReferences() {
+	composedOnCluster, err := collectComposedResources(claimOnCluster, compositeOnCluster, func(ref corev1.ObjectReference) (*xpcomposed.Unstructured, error) {
 		childAtCluster := xpcomposed.New(xpcomposed.FromReference(ref))
 		if err := klient.Get(ctx, kube, ref.Name, ref.Namespace, childAtCluster); err != nil {
 			if kerrors.IsNotFound(err) {
-				continue
+				return nil, nil
 			}
-			return claimOnCluster, compositeOnCluster, composedOnCluster, errors.Wrapf(err, "cannot get object %q with name %q", ref.GroupVersionKind().String(), ref.Name)
+			return nil, errors.Wrapf(err, "cannot get object %q with name %q", ref.GroupVersionKind().String(), ref.Name)
 		}
-		composedOnCluster = append(composedOnCluster, childAtCluster)
+		return childAtCluster, nil
+	})
+	return claimOnCluster, compositeOnCluster, composedOnCluster, err
+}
+
+// maxResourceTreeDepth limits how deep nested composites are collected below
+// the composite of a claim.
+const maxResourceTreeDepth = 10
+
+// getComposedFunc retrieves the composed resource for ref. It returns nil if
+// the resource does not exist.
+type getComposedFunc func(ref corev1.ObjectReference) (*xpcomposed.Unstructured, error)
+
+type treeObjectKey struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+}
+
+func treeObjectKeyOf(o client.Object) treeObjectKey {
+	return treeObjectKey{gvk: o.GetObjectKind().GroupVersionKind(), namespace: o.GetNamespace(), name: o.GetName()}
+}
+
+// collectComposedResources of composite. Composed resources that are
+// composites themselves are walked recursively, each object is only
+// collected once. The objects are returned in depth-first order.
+func collectComposedResources(claim client.Object, composite *xpcomposite.Unstructured, get getComposedFunc) ([]*xpcomposed.Unstructured, error) {
+	visited := map[treeObjectKey]bool{
+		treeObjectKeyOf(composite): true,
+	}
+	if claim != nil {
+		visited[treeObjectKeyOf(claim)] = true
 	}
-	return claimOnCluster, compositeOnCluster, composedOnCluster, nil
+	return collectComposedResourcesRecursive(composite, get, visited, 1)
+}
+
+func collectComposedResourcesRecursive(composite *xpcomposite.Unstructured, get getComposedFunc, visited map[treeObjectKey]bool, depth int) ([]*xpcomposed.Unstructured, error) {
+	if depth > maxResourceTreeDepth {
+		return nil, errors.Errorf("composite %q exceeds the maximum nesting depth of %d", composite.GetName(), maxResourceTreeDepth)
+	}
+	var composed []*xpcomposed.Unstructured
+	for _, ref := range composite.GetResourceReferences() {
+		key := treeObjectKey{gvk: ref.GroupVersionKind(), namespace: ref.Namespace, name: ref.Name}
+		if visited[key] {
+			continue // protect against reference cycles
+		}
+		visited[key] = true
+
+		child, err := get(ref)
+		if err != nil {
+			return composed, err
+		}
+		if child == nil {
+			continue
+		}
+		composed = append(composed, child)
+
+		// Composed resources with resource references are nested composites.
+		nested := asComposite(child)
+		if len(nested.GetResourceReferences()) == 0 {
+			continue
+		}
+		children, err := collectComposedResourcesRecursive(nested, get, visited, depth+1)
+		composed = append(composed, children...)
+		if err != nil {
+			return composed, err
+		}
+	}
+	return composed, nil
+}
+
+func asComposite(o *xpcomposed.Unstructured) *xpcomposite.Unstructured {
+	return &xpcomposite.Unstructured{Unstructured: o.Unstructured}
 }
 
 func combineObjectsToSlice(claim *xpclaim.Unstructured, composite *xpcomposite.Unstructured, composed []*xpcomposed.Unstructured) []client.Object {
-	s := make([]client.Object, len(composed)+2)
-	s[0] = claim
-	s[1] = composite
-	for i, c := range composed {
-		s[i+2] = c
+	s := make([]client.Object, 0, len(composed)+2)
+	// avoid typed nil values in the interface slice
+	if claim != nil {
+		s = append(s, claim)
+	}
+	if composite != nil {
+		s = append(s, composite)
+	}
+	for _, c := range composed {
+		if c != nil {
+			s = append(s, c)
+		}
 	}
 	return s
 }
@@ -112,11 +190,3 @@ func prettyPrintObjects(objects []client.Object, filter objectFilter) string {
 	}
 	return buf.String()
 }
-
-func skipReadyAndSynced(o client.Object) bool {
-	cond, ok := o.(objectWithConditions)
-	if !ok {
-		return false
-	}
-	return isObjectSyncedAndReady(cond)
-}
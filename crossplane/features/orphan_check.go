@@ -0,0 +1,133 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package features
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apimachinerywait "k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/crossplane/internal/meta"
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/klient"
+)
+
+// deletionPolicyOrphan of managed resources keeps the external resource if
+// the managed resource is deleted.
+const deletionPolicyOrphan = "Orphan"
+
+// snapshotObject is an object of a resource tree recorded before the tree is
+// deleted.
+type snapshotObject struct {
+	gvk                     schema.GroupVersionKind
+	namespace               string
+	name                    string
+	compositionResourceName string
+	orphanPolicy            bool
+}
+
+func (o snapshotObject) String() string {
+	s := fmt.Sprintf("%s %s", o.gvk.GroupKind().String(), strings.Join(nonEmpty(o.namespace, o.name), "/"))
+	if o.compositionResourceName != "" {
+		s += fmt.Sprintf(" (%s)", o.compositionResourceName)
+	}
+	return s
+}
+
+// snapshotResourceTree records the composite and all composed resources of
+// claim.
+func snapshotResourceTree(ctx context.Context, kube klient.Client, claim client.Object) ([]snapshotObject, error) {
+	_, composite, composed, err := collectResourceTree(ctx, kube, claim)
+	if err != nil {
+		return nil, err
+	}
+	objects := combineObjectsToSlice(nil, composite, composed)
+	snapshot := make([]snapshotObject, 0, len(objects))
+	for _, o := range objects {
+		if o.GetName() == "" {
+			continue // not found on the cluster
+		}
+		policy := ""
+		if u, ok := o.(interface{ UnstructuredContent() map[string]any }); ok {
+			policy, _, _ = unstructured.NestedString(u.UnstructuredContent(), "spec", "deletionPolicy")
+		}
+		snapshot = append(snapshot, snapshotObject{
+			gvk:                     o.GetObjectKind().GroupVersionKind(),
+			namespace:               o.GetNamespace(),
+			name:                    o.GetName(),
+			compositionResourceName: meta.GetCompositionResourceName(o),
+			orphanPolicy:            policy == deletionPolicyOrphan,
+		})
+	}
+	return snapshot, nil
+}
+
+// isAllowedToRemain returns true if o is explicitly allowed to remain after
+// its claim has been deleted.
+func (c *WaitConfig) isAllowedToRemain(o snapshotObject) bool {
+	return o.compositionResourceName != "" && slices.Contains(c.allowRemainingByCompositionResourceName, o.compositionResourceName)
+}
+
+// remainingObjects returns all objects of snapshot that still exist on the
+// cluster and are not allowed to remain.
+func remainingObjects(ctx context.Context, kube klient.Client, snapshot []snapshotObject, waitCfg *WaitConfig) ([]snapshotObject, error) {
+	var remaining []snapshotObject
+	for _, o := range snapshot {
+		if waitCfg.isAllowedToRemain(o) {
+			continue
+		}
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(o.gvk)
+		err := kube.Resources().GetControllerRuntimeClient().Get(ctx, client.ObjectKey{Namespace: o.namespace, Name: o.name}, u)
+		if kerrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get %s", o.String())
+		}
+		remaining = append(remaining, o)
+	}
+	return remaining, nil
+}
+
+// areSnapshotObjectsDeleted checks that all objects of snapshot that are not
+// allowed to remain have been deleted.
+func areSnapshotObjectsDeleted(kube klient.Client, snapshot []snapshotObject, waitCfg *WaitConfig) apimachinerywait.ConditionWithContextFunc {
+	return func(ctx context.Context) (bool, error) {
+		remaining, err := remainingObjects(ctx, kube, snapshot, waitCfg)
+		if err != nil {
+			return false, nil //nolint:nilerr // retry until the timeout is reached
+		}
+		return len(remaining) == 0, nil
+	}
+}
+
+// describeLeftovers of a deleted resource tree. It lists all objects that
+// still exist and all managed resources with the Orphan deletion policy, as
+// their external resources are kept. It returns an empty string if there are
+// no leftovers.
+func describeLeftovers(ctx context.Context, kube klient.Client, snapshot []snapshotObject, waitCfg *WaitConfig) (string, error) {
+	remaining, err := remainingObjects(ctx, kube, snapshot, waitCfg)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	for _, o := range remaining {
+		fmt.Fprintf(buf, "  %s: still exists\n", o.String())
+	}
+	for _, o := range snapshot {
+		if o.orphanPolicy && !waitCfg.isAllowedToRemain(o) {
+			fmt.Fprintf(buf, "  %s: deletion policy %s keeps the external resource\n", o.String(), deletionPolicyOrphan)
+		}
+	}
+	return buf.String(), nil
+}
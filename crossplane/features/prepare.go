@@ -0,0 +1,31 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package features
+
+import (
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/klient"
+)
+
+// prepareObjects sets the namespace of all namespaced objects without a
+// namespace to the given default test namespace. It must be called by every
+// feature before objects passed by the caller are looked up on the cluster.
+func prepareObjects(kube klient.Client, namespace string, objects ...client.Object) error {
+	kubeClient := kube.Resources().GetControllerRuntimeClient()
+	for _, o := range objects {
+		if o.GetNamespace() != "" {
+			continue
+		}
+		isObjectNamespaced, err := kubeClient.IsObjectNamespaced(o)
+		if err != nil {
+			return errors.Wrapf(err, "cannot determine scope of %s %q", o.GetObjectKind().GroupVersionKind().Kind, o.GetName())
+		}
+		if isObjectNamespaced {
+			o.SetNamespace(namespace)
+		}
+	}
+	return nil
+}
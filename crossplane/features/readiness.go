@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package features
+
+import (
+	"slices"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/crossplane/internal/meta"
+)
+
+// ReadinessPredicate determines if o is considered ready during a WaitFor
+// operation. It replaces the check of the "Synced" and "Ready" conditions.
+type ReadinessPredicate func(o *unstructured.Unstructured) bool
+
+// ReadyWhenFieldEquals returns a ReadinessPredicate that considers an object
+// ready if the string value at the given field path equals value, for example
+// ReadyWhenFieldEquals("status.atProvider.manifest.status.phase", "Running").
+func ReadyWhenFieldEquals(path, value string) ReadinessPredicate {
+	return func(o *unstructured.Unstructured) bool {
+		v, err := fieldpath.Pave(o.Object).GetString(path)
+		return err == nil && v == value
+	}
+}
+
+// isIgnored returns true if o is explicitly excluded from readiness checks.
+func (c *WaitConfig) isIgnored(o objectWithConditions) bool {
+	return slices.Contains(c.ignoreComposedByCompositionResourceName, meta.GetCompositionResourceName(o))
+}
+
+// readinessPredicateFor returns the readiness predicate that is registered for
+// o, if any. Predicates registered by composition resource name take
+// precedence over predicates registered by group kind.
+func (c *WaitConfig) readinessPredicateFor(o objectWithConditions) ReadinessPredicate {
+	if p, ok := c.readinessByCompositionResourceName[meta.GetCompositionResourceName(o)]; ok {
+		return p
+	}
+	return c.readinessByGroupKind[o.GetObjectKind().GroupVersionKind().GroupKind()]
+}
+
+// isObjectReady checks o with the readiness predicate registered for it or
+// falls back to its "Synced" and "Ready" conditions.
+func (c *WaitConfig) isObjectReady(o objectWithConditions) bool {
+	if o == nil {
+		return false
+	}
+	if p := c.readinessPredicateFor(o); p != nil {
+		return evaluateReadinessPredicate(p, o)
+	}
+	return isObjectSyncedAndReady(o, c.kindRegistry())
+}
+
+// kindRegistry returns the registry of kinds without conditions of the wait
+// operation or the default registry if none is set.
+func (c *WaitConfig) kindRegistry() *KindRegistry {
+	if c.kindsWithoutConditions == nil {
+		return defaultKindsWithoutConditions
+	}
+	return c.kindsWithoutConditions
+}
+
+func evaluateReadinessPredicate(p ReadinessPredicate, o objectWithConditions) bool {
+	u, ok := o.(runtime.Unstructured)
+	if !ok {
+		return false
+	}
+	return p(&unstructured.Unstructured{Object: u.UnstructuredContent()})
+}
@@ -0,0 +1,251 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package features
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	xpclaim "github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	xpcomposed "github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composed"
+	xpcomposite "github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/crossplane/internal/meta"
+)
+
+// TreeRole is the role of an object in a claim resource tree.
+type TreeRole string
+
+const (
+	TreeRoleClaim     TreeRole = "claim"
+	TreeRoleComposite TreeRole = "composite"
+	TreeRoleComposed  TreeRole = "composed"
+)
+
+// TreeReportRow describes the state of a single object in a claim resource
+// tree.
+type TreeReportRow struct {
+	Role                    TreeRole
+	GroupVersionKind        schema.GroupVersionKind
+	Namespace               string
+	Name                    string
+	CompositionResourceName string
+
+	// Depth of the object below the claim. Resources composed by nested
+	// composites have a depth greater than 2.
+	Depth int
+
+	// Synced and Ready are empty if the object kind does not have
+	// conditions.
+	Synced  corev1.ConditionStatus
+	Ready   corev1.ConditionStatus
+	Reason  xpv1.ConditionReason
+	Message string
+
+	// Ignored is true if the object is excluded from readiness checks.
+	Ignored bool
+
+	// CustomReadiness is set if the readiness of the object is determined by a
+	// ReadinessPredicate instead of its conditions.
+	CustomReadiness *bool
+}
+
+// IsSyncedAndReady returns true if the object of this row is considered
+// ready.
+func (r TreeReportRow) IsSyncedAndReady() bool {
+	if r.Ignored {
+		return true
+	}
+	if r.CustomReadiness != nil {
+		return *r.CustomReadiness
+	}
+	return (r.Synced == "" || r.Synced == corev1.ConditionTrue) && (r.Ready == "" || r.Ready == corev1.ConditionTrue)
+}
+
+// TreeReport is a structured readiness report of a claim, its composite and
+// all composed resources.
+type TreeReport struct {
+	Rows []TreeReportRow
+
+	objects []client.Object
+}
+
+func newTreeReport(claim *xpclaim.Unstructured, composite *xpcomposite.Unstructured, composed []*xpcomposed.Unstructured, waitCfg *WaitConfig) *TreeReport {
+	r := &TreeReport{}
+	if claim != nil {
+		r.add(TreeRoleClaim, claim, 0, waitCfg)
+	}
+	if composite == nil {
+		return r
+	}
+	r.add(TreeRoleComposite, composite, 1, waitCfg)
+
+	// composed is ordered depth-first, so the depth of nested composites is
+	// known before their children are added.
+	depths := map[treeObjectKey]int{}
+	for _, ref := range composite.GetResourceReferences() {
+		depths[treeObjectKey{gvk: ref.GroupVersionKind(), namespace: ref.Namespace, name: ref.Name}] = 2
+	}
+	for _, o := range composed {
+		if o == nil {
+			continue
+		}
+		depth := max(depths[treeObjectKeyOf(o)], 2)
+		for _, ref := range asComposite(o).GetResourceReferences() {
+			depths[treeObjectKey{gvk: ref.GroupVersionKind(), namespace: ref.Namespace, name: ref.Name}] = depth + 1
+		}
+		r.add(TreeRoleComposed, o, depth, waitCfg)
+	}
+	return r
+}
+
+func (r *TreeReport) add(role TreeRole, o objectWithConditions, depth int, waitCfg *WaitConfig) {
+	row := TreeReportRow{
+		Role:                    role,
+		Depth:                   depth,
+		GroupVersionKind:        o.GetObjectKind().GroupVersionKind(),
+		Namespace:               o.GetNamespace(),
+		Name:                    o.GetName(),
+		CompositionResourceName: meta.GetCompositionResourceName(o),
+		Ignored:                 role == TreeRoleComposed && waitCfg.isIgnored(o),
+	}
+	if p := waitCfg.readinessPredicateFor(o); p != nil {
+		ready := evaluateReadinessPredicate(p, o)
+		row.CustomReadiness = &ready
+	}
+	if hasObjectStatusConditions(o, waitCfg.kindRegistry()) {
+		synced := o.GetCondition(xpv1.TypeSynced)
+		ready := o.GetCondition(xpv1.TypeReady)
+		row.Synced = synced.Status
+		row.Ready = ready.Status
+		// Report the reason of the first condition that is not fulfilled.
+		cond := ready
+		if synced.Status != corev1.ConditionTrue {
+			cond = synced
+		}
+		row.Reason = cond.Reason
+		row.Message = cond.Message
+	}
+	r.Rows = append(r.Rows, row)
+	r.objects = append(r.objects, o)
+}
+
+// IsSyncedAndReady returns true if all rows of the report are considered
+// ready.
+func (r *TreeReport) IsSyncedAndReady() bool {
+	for _, row := range r.Rows {
+		if !row.IsSyncedAndReady() {
+			return false
+		}
+	}
+	return true
+}
+
+// String renders the report as a compact table.
+func (r *TreeReport) String() string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ROLE\tKIND\tNAME\tRESOURCE-NAME\tSYNCED\tREADY\tREASON\tMESSAGE")
+	for _, row := range r.Rows {
+		name := row.Name
+		if row.Namespace != "" {
+			name = row.Namespace + "/" + row.Name
+		}
+		ready := orDash(string(row.Ready))
+		switch {
+		case row.Ignored:
+			ready += " (ignored)"
+		case row.CustomReadiness != nil:
+			ready = fmt.Sprintf("%t (custom)", *row.CustomReadiness)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			row.Role,
+			strings.Repeat("  ", max(row.Depth-2, 0))+row.GroupVersionKind.GroupKind().String(),
+			name,
+			orDash(row.CompositionResourceName),
+			orDash(string(row.Synced)),
+			ready,
+			orDash(string(row.Reason)),
+			strings.ReplaceAll(row.Message, "\n", " "),
+		)
+	}
+	_ = w.Flush()
+	return buf.String()
+}
+
+// YAML renders the full manifests of all objects in the report that are not
+// synced and ready.
+func (r *TreeReport) YAML() string {
+	unready := make([]client.Object, 0, len(r.objects))
+	for i, row := range r.Rows {
+		if !row.IsSyncedAndReady() {
+			unready = append(unready, r.objects[i])
+		}
+	}
+	return prettyPrintObjects(unready, nil)
+}
+
+// ClaimsReportRow describes whether a single claim of a bulk wait has
+// converged.
+type ClaimsReportRow struct {
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+	Ready            bool
+	// Duration from the start of the wait until the claim was ready or the
+	// wait for it was given up.
+	Duration time.Duration
+	Err      error
+}
+
+// ClaimsReport summarizes a wait for multiple claims.
+type ClaimsReport struct {
+	Rows []ClaimsReportRow
+}
+
+// IsReady returns true if all claims of the report are ready.
+func (r *ClaimsReport) IsReady() bool {
+	for _, row := range r.Rows {
+		if !row.Ready {
+			return false
+		}
+	}
+	return true
+}
+
+// String renders the report as a compact table.
+func (r *ClaimsReport) String() string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tREADY\tDURATION\tERROR")
+	for _, row := range r.Rows {
+		errMsg := ""
+		if row.Err != nil {
+			errMsg = row.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\n",
+			row.GroupVersionKind.GroupKind().String(),
+			strings.Join(nonEmpty(row.Namespace, row.Name), "/"),
+			row.Ready,
+			row.Duration.Round(time.Millisecond),
+			orDash(errMsg),
+		)
+	}
+	_ = w.Flush()
+	return buf.String()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,224 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package features
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	xpclaim "github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	xpcomposed "github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composed"
+	xpcomposite "github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ReadinessTiming records when an object of a claim resource tree was first
+// observed Synced and Ready, relative to the start of the wait for its claim.
+type ReadinessTiming struct {
+	// Claim is the namespace/name of the claim whose tree contains the object.
+	Claim                   string
+	Role                    TreeRole
+	Kind                    string
+	Namespace               string
+	Name                    string
+	CompositionResourceName string
+
+	// Synced and Ready are nil if the condition was never observed.
+	Synced *time.Duration
+	Ready  *time.Duration
+}
+
+// ReadinessTimings collects the readiness timings of all WaitForClaimReady
+// and WaitForClaimsReady operations that share a context. It is safe for
+// concurrent use.
+type ReadinessTimings struct {
+	mu      sync.Mutex
+	entries []ReadinessTiming
+	index   map[string]int
+}
+
+func newReadinessTimings() *ReadinessTimings {
+	return &ReadinessTimings{index: map[string]int{}}
+}
+
+// Entries returns a copy of all recorded timings in the order the objects
+// were first observed.
+func (r *ReadinessTimings) Entries() []ReadinessTiming {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]ReadinessTiming, len(r.entries))
+	copy(out, r.entries)
+	return out
+}
+
+func (r *ReadinessTimings) observe(claim string, after time.Duration, row TreeReportRow) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := strings.Join([]string{claim, string(row.Role), row.GroupVersionKind.String(), row.Namespace, row.Name}, "|")
+	i, ok := r.index[key]
+	if !ok {
+		i = len(r.entries)
+		r.index[key] = i
+		r.entries = append(r.entries, ReadinessTiming{
+			Claim:                   claim,
+			Role:                    row.Role,
+			Kind:                    row.GroupVersionKind.GroupKind().String(),
+			Namespace:               row.Namespace,
+			Name:                    row.Name,
+			CompositionResourceName: row.CompositionResourceName,
+		})
+	}
+	e := &r.entries[i]
+	if e.Synced == nil && (row.Synced == "" || row.Synced == corev1.ConditionTrue) {
+		e.Synced = &after
+	}
+	ready := row.Ready == "" || row.Ready == corev1.ConditionTrue
+	if row.CustomReadiness != nil {
+		ready = *row.CustomReadiness
+	}
+	if e.Ready == nil && ready {
+		e.Ready = &after
+	}
+}
+
+type readinessTimingJSON struct {
+	Claim                   string   `json:"claim"`
+	Role                    TreeRole `json:"role"`
+	Kind                    string   `json:"kind"`
+	Namespace               string   `json:"namespace,omitempty"`
+	Name                    string   `json:"name"`
+	CompositionResourceName string   `json:"compositionResourceName,omitempty"`
+	SyncedSeconds           *float64 `json:"syncedSeconds"`
+	ReadySeconds            *float64 `json:"readySeconds"`
+}
+
+func seconds(d *time.Duration) *float64 {
+	if d == nil {
+		return nil
+	}
+	s := d.Seconds()
+	return &s
+}
+
+// WriteJSON writes all timings as a JSON array. Durations are given in
+// seconds and are null if the condition was never observed.
+func (r *ReadinessTimings) WriteJSON(w io.Writer) error {
+	entries := r.Entries()
+	out := make([]readinessTimingJSON, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, readinessTimingJSON{
+			Claim:                   e.Claim,
+			Role:                    e.Role,
+			Kind:                    e.Kind,
+			Namespace:               e.Namespace,
+			Name:                    e.Name,
+			CompositionResourceName: e.CompositionResourceName,
+			SyncedSeconds:           seconds(e.Synced),
+			ReadySeconds:            seconds(e.Ready),
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// WriteCSV writes all timings as CSV with a header row. Durations are given
+// in seconds and are empty if the condition was never observed.
+func (r *ReadinessTimings) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"claim", "role", "kind", "namespace", "name", "compositionResourceName", "syncedSeconds", "readySeconds"})
+	for _, e := range r.Entries() {
+		_ = cw.Write([]string{e.Claim, string(e.Role), e.Kind, e.Namespace, e.Name, e.CompositionResourceName, formatSeconds(e.Synced), formatSeconds(e.Ready)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatSeconds(d *time.Duration) string {
+	if d == nil {
+		return ""
+	}
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// writeFile writes all timings to path. The format is CSV if path has the
+// extension ".csv" and JSON otherwise.
+func (r *ReadinessTimings) writeFile(path string) error {
+	f, err := os.Create(filepath.Clean(path))
+	if err != nil {
+		return errors.Wrap(err, "cannot create readiness timings file")
+	}
+	write := r.WriteJSON
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		write = r.WriteCSV
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "cannot write readiness timings")
+	}
+	return errors.Wrap(f.Close(), "cannot write readiness timings")
+}
+
+type readinessTimingsContextKey struct{}
+
+// ReadinessTimingsFromContext returns the readiness timings recorded by
+// WaitForClaimReady and WaitForClaimsReady. It returns nil if no timings have
+// been recorded.
+func ReadinessTimingsFromContext(ctx context.Context) *ReadinessTimings {
+	r, _ := ctx.Value(readinessTimingsContextKey{}).(*ReadinessTimings)
+	return r
+}
+
+// contextWithReadinessTimings returns ctx and the timings stored in it. A new
+// collection is added to the context if there is none.
+func contextWithReadinessTimings(ctx context.Context) (context.Context, *ReadinessTimings) {
+	if r := ReadinessTimingsFromContext(ctx); r != nil {
+		return ctx, r
+	}
+	r := newReadinessTimings()
+	return context.WithValue(ctx, readinessTimingsContextKey{}, r), r
+}
+
+// timingObserver records the readiness timings of the resource tree of a
+// single claim.
+type timingObserver struct {
+	timings *ReadinessTimings
+	claim   string
+	start   time.Time
+}
+
+// newTimingObserver records timings of the tree of claim relative to start,
+// the start of the wait.
+func newTimingObserver(timings *ReadinessTimings, claim client.Object, start time.Time) *timingObserver {
+	return &timingObserver{
+		timings: timings,
+		claim:   client.ObjectKeyFromObject(claim).String(),
+		start:   start,
+	}
+}
+
+// observeTree records the state of all objects of a resource tree. It does
+// nothing if no observer is configured.
+func (c *WaitConfig) observeTree(claim *xpclaim.Unstructured, composite *xpcomposite.Unstructured, composed []*xpcomposed.Unstructured) {
+	if c.timingObserver == nil {
+		return
+	}
+	after := time.Since(c.timingObserver.start)
+	for _, row := range newTreeReport(claim, composite, composed, c).Rows {
+		if row.Name == "" {
+			continue // not found on the cluster
+		}
+		c.timingObserver.timings.observe(c.timingObserver.claim, after, row)
+	}
+}
@@ -0,0 +1,267 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package features
+
+import (
+	"context"
+
+	xpclaim "github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	xpcomposed "github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composed"
+	xpcomposite "github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/crossplane/internal/meta"
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/klient"
+)
+
+// watchUnavailableError is returned if the resource tree cannot be watched and
+// the caller should fall back to polling.
+type watchUnavailableError struct {
+	error
+}
+
+func isWatchUnavailable(err error) bool {
+	var target *watchUnavailableError
+	return errors.As(err, &target)
+}
+
+// watchTarget identifies a single list and watch request.
+type watchTarget struct {
+	gvk           schema.GroupVersionKind
+	namespace     string
+	fieldSelector string
+	labelSelector string
+}
+
+func (wt watchTarget) newList() *unstructured.UnstructuredList {
+	l := &unstructured.UnstructuredList{}
+	l.SetGroupVersionKind(wt.gvk.GroupVersion().WithKind(wt.gvk.Kind + "List"))
+	return l
+}
+
+func (wt watchTarget) listOptions(resourceVersion string) *client.ListOptions {
+	return &client.ListOptions{
+		Namespace: wt.namespace,
+		Raw: &metav1.ListOptions{
+			FieldSelector:   wt.fieldSelector,
+			LabelSelector:   wt.labelSelector,
+			ResourceVersion: resourceVersion,
+		},
+	}
+}
+
+type watchEvent struct {
+	target  watchTarget
+	watcher watch.Interface
+	event   watch.Event
+	closed  bool
+}
+
+// treeWatcher keeps a local copy of the resource tree of a claim that is
+// updated from watch events. Watches are started lazily for every group
+// version kind that is discovered in the tree.
+type treeWatcher struct {
+	kube        client.WithWatch
+	sourceClaim client.Object
+	stores      map[watchTarget]map[client.ObjectKey]*unstructured.Unstructured
+	watches     map[watchTarget]watch.Interface
+	events      chan watchEvent
+	done        chan struct{}
+}
+
+func newTreeWatcher(kube klient.Client, sourceClaim client.Object) (*treeWatcher, error) {
+	kubeClient := kube.Resources().GetControllerRuntimeClient()
+	watchClient, err := client.NewWithWatch(kube.RESTConfig(), client.Options{
+		Scheme: kubeClient.Scheme(),
+		Mapper: kubeClient.RESTMapper(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &treeWatcher{
+		kube:        watchClient,
+		sourceClaim: sourceClaim,
+		stores:      map[watchTarget]map[client.ObjectKey]*unstructured.Unstructured{},
+		watches:     map[watchTarget]watch.Interface{},
+		events:      make(chan watchEvent),
+		done:        make(chan struct{}),
+	}, nil
+}
+
+// waitForClaimReadyWithWatch waits until the resource tree of sourceClaim is
+// synced and ready. The tree is only re-evaluated when a watch event is
+// received. A watchUnavailableError is returned if any watch cannot be
+// established.
+func waitForClaimReadyWithWatch(ctx context.Context, kube klient.Client, sourceClaim client.Object, waitCfg *WaitConfig) error {
+	w, err := newTreeWatcher(kube, sourceClaim)
+	if err != nil {
+		return &watchUnavailableError{errors.Wrap(err, "cannot create watch client")}
+	}
+	defer w.stop()
+
+	for {
+		claim, composite, composed, err := w.resourceTree(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return &watchUnavailableError{err}
+		}
+		waitCfg.observeTree(claim, composite, composed)
+		if isResourceTreeSyncedAndReady(claim, composite, composed, waitCfg) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev := <-w.events:
+			if err := w.handle(ctx, ev); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return &watchUnavailableError{err}
+			}
+		}
+	}
+}
+
+// resourceTree assembles the claim, composite and composed resources from the
+// local store in the same way collectResourceTree does from the API server.
+func (w *treeWatcher) resourceTree(ctx context.Context) (*xpclaim.Unstructured, *xpcomposite.Unstructured, []*xpcomposed.Unstructured, error) {
+	claimGVK := w.sourceClaim.GetObjectKind().GroupVersionKind()
+	claimOnCluster := xpclaim.New(xpclaim.WithGroupVersionKind(claimGVK))
+	u, err := w.get(ctx, watchTarget{
+		gvk:           claimGVK,
+		namespace:     w.sourceClaim.GetNamespace(),
+		fieldSelector: "metadata.name=" + w.sourceClaim.GetName(),
+	}, client.ObjectKeyFromObject(w.sourceClaim))
+	if err != nil || u == nil {
+		return claimOnCluster, nil, nil, errors.Wrap(err, "cannot watch claim")
+	}
+	claimOnCluster.Unstructured = *u
+
+	compositeRef := claimOnCluster.GetResourceReference()
+	if compositeRef == nil {
+		return claimOnCluster, nil, nil, nil
+	}
+	compositeOnCluster := xpcomposite.New(xpcomposite.WithGroupVersionKind(compositeRef.GroupVersionKind()))
+	u, err = w.get(ctx, watchTarget{
+		gvk:           compositeRef.GroupVersionKind(),
+		fieldSelector: "metadata.name=" + compositeRef.Name,
+	}, client.ObjectKey{Name: compositeRef.Name})
+	if err != nil || u == nil {
+		return claimOnCluster, compositeOnCluster, nil, errors.Wrap(err, "cannot watch composite")
+	}
+	compositeOnCluster.Unstructured = *u
+
+	// Composed resources are watched per kind and selected by the composite
+	// label that crossplane propagates from the root composite to all nested
+	// composites and their composed resources.
+	compositeLabel := meta.GetNamePrefixForComposed(compositeOnCluster)
+	if compositeLabel == "" {
+		compositeLabel = compositeOnCluster.GetName()
+	}
+	composedOnCluster, err := collectComposedResources(claimOnCluster, compositeOnCluster, func(ref corev1.ObjectReference) (*xpcomposed.Unstructured, error) {
+		u, err := w.get(ctx, watchTarget{
+			gvk:           ref.GroupVersionKind(),
+			labelSelector: meta.LabelKeyNamePrefixForComposed + "=" + compositeLabel,
+		}, client.ObjectKey{Name: ref.Name, Namespace: ref.Namespace})
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot watch object %q", ref.GroupVersionKind().String())
+		}
+		if u == nil {
+			return nil, nil
+		}
+		return &xpcomposed.Unstructured{Unstructured: *u}, nil
+	})
+	return claimOnCluster, compositeOnCluster, composedOnCluster, err
+}
+
+// get an object from the local store of target. The watch for target is
+// started if it is not running yet. It returns nil if the object does not
+// exist.
+func (w *treeWatcher) get(ctx context.Context, target watchTarget, key client.ObjectKey) (*unstructured.Unstructured, error) {
+	if _, ok := w.watches[target]; !ok {
+		if err := w.start(ctx, target); err != nil {
+			return nil, err
+		}
+	}
+	return w.stores[target][key], nil
+}
+
+// start lists all objects of target and watches for changes starting from
+// the resource version of the list.
+func (w *treeWatcher) start(ctx context.Context, target watchTarget) error {
+	list := target.newList()
+	if err := w.kube.List(ctx, list, target.listOptions("")); err != nil {
+		return errors.Wrapf(err, "cannot list %q", target.gvk.String())
+	}
+	store := make(map[client.ObjectKey]*unstructured.Unstructured, len(list.Items))
+	for i := range list.Items {
+		store[client.ObjectKeyFromObject(&list.Items[i])] = &list.Items[i]
+	}
+	w.stores[target] = store
+
+	watcher, err := w.kube.Watch(ctx, target.newList(), target.listOptions(list.GetResourceVersion()))
+	if err != nil {
+		return errors.Wrapf(err, "cannot watch %q", target.gvk.String())
+	}
+	w.watches[target] = watcher
+	go w.forward(target, watcher)
+	return nil
+}
+
+// forward the events of watcher to the shared event channel until the watch
+// is closed or the treeWatcher is stopped.
+func (w *treeWatcher) forward(target watchTarget, watcher watch.Interface) {
+	for ev := range watcher.ResultChan() {
+		select {
+		case w.events <- watchEvent{target: target, watcher: watcher, event: ev}:
+		case <-w.done:
+			return
+		}
+	}
+	select {
+	case w.events <- watchEvent{target: target, watcher: watcher, closed: true}:
+	case <-w.done:
+	}
+}
+
+// handle updates the local store from a watch event.
+func (w *treeWatcher) handle(ctx context.Context, ev watchEvent) error {
+	if w.watches[ev.target] != ev.watcher {
+		return nil // event of a watch that has already been replaced
+	}
+	if ev.closed || ev.event.Type == watch.Error {
+		// The API server closes watches regularly, so start over with a fresh
+		// list of the target.
+		ev.watcher.Stop()
+		delete(w.watches, ev.target)
+		return w.start(ctx, ev.target)
+	}
+	u, ok := ev.event.Object.(*unstructured.Unstructured)
+	if !ok {
+		return nil
+	}
+	switch ev.event.Type {
+	case watch.Added, watch.Modified:
+		w.stores[ev.target][client.ObjectKeyFromObject(u)] = u
+	case watch.Deleted:
+		delete(w.stores[ev.target], client.ObjectKeyFromObject(u))
+	}
+	return nil
+}
+
+func (w *treeWatcher) stop() {
+	close(w.done)
+	for _, watcher := range w.watches {
+		watcher.Stop()
+	}
+}
@@ -8,8 +8,10 @@ import (
 )
 
 const (
-	LabelKeyClaimName      = "crossplane.io/claim-name"
-	LabelKeyClaimNamespace = "crossplane.io/claim-namespace"
+	LabelKeyClaimName             = "crossplane.io/claim-name"
+	LabelKeyClaimNamespace        = "crossplane.io/claim-namespace"
+	LabelKeyNamePrefixForComposed = "crossplane.io/composite"
+	LabelKeyCompositionName       = "crossplane.io/composition-name"
 )
 
 // GetClaimName stored in the label of o or an empty string of it
@@ -23,3 +25,10 @@ func GetClaimName(o metav1.Object) string {
 func GetClaimNamespace(o metav1.Object) string {
 	return o.GetLabels()[LabelKeyClaimNamespace]
 }
+
+// GetNamePrefixForComposed stored in the label of o or an empty string of it
+// is not defined. Crossplane propagates this label from the root composite to
+// all composed resources.
+func GetNamePrefixForComposed(o metav1.Object) string {
+	return o.GetLabels()[LabelKeyNamePrefixForComposed]
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/crossplane/crossplane-runtime/pkg/resource"
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/dbinfrago/kubernetes-e2e-test-framework/crossplane/resources/connectiondetails"
@@ -18,40 +19,55 @@ import (
 // NewClientFromClaimConnectionDetails creates a new kube client from a
 // kubeconfig that is exposed in connection details secret of a claim
 // resource.
-func NewClientFromClaimConnectionDetails(ctx context.Context, kube klient.Client, claim resource.CompositeClaim, connectionDetailsKey string) (klient.Client, error) {
+func NewClientFromClaimConnectionDetails(ctx context.Context, kube klient.Client, claim resource.CompositeClaim, connectionDetailsKey string, opts ...Option) (klient.Client, error) {
 	connectionDetails, err := connectiondetails.FromClaim(ctx, kube, claim)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot get cluster connection details")
 	}
-	return newClientFromConnectionDetails(connectionDetails, connectionDetailsKey)
+	return newClientFromConnectionDetails(ctx, connectionDetails, connectionDetailsKey, opts...)
 }
 
 // NewClientFromCompositeConnectionDetails creates a new kube client from a
 // kubeconfig that is exposed in connection details secret of a composite
 // resource.
-func NewClientFromCompositeConnectionDetails(ctx context.Context, kube klient.Client, composite resource.Composite, connectionDetailsKey string) (klient.Client, error) {
+func NewClientFromCompositeConnectionDetails(ctx context.Context, kube klient.Client, composite resource.Composite, connectionDetailsKey string, opts ...Option) (klient.Client, error) {
 	connectionDetails, err := connectiondetails.FromComposite(ctx, kube, composite)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot get cluster connection details")
 	}
-	return newClientFromConnectionDetails(connectionDetails, connectionDetailsKey)
+	return newClientFromConnectionDetails(ctx, connectionDetails, connectionDetailsKey, opts...)
 }
 
 // NewClientFromComposedConnectionDetails creates a new kube client from a
 // kubeconfig that is exposed in connection details secret of a composed
 // resource.
-func NewClientFromComposedConnectionDetails(ctx context.Context, kube klient.Client, claim client.Object, composedResourceName string, composedResourceGVK schema.GroupVersionKind, connectionDetailsKey string) (klient.Client, error) {
+func NewClientFromComposedConnectionDetails(ctx context.Context, kube klient.Client, claim client.Object, composedResourceName string, composedResourceGVK schema.GroupVersionKind, connectionDetailsKey string, opts ...Option) (klient.Client, error) {
 	connectionDetails, err := connectiondetails.FromComposedByClaim(ctx, kube, claim, composedResourceName, composedResourceGVK)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot get cluster connection details")
 	}
-	return newClientFromConnectionDetails(connectionDetails, connectionDetailsKey)
+	return newClientFromConnectionDetails(ctx, connectionDetails, connectionDetailsKey, opts...)
 }
 
-func newClientFromConnectionDetails(cd map[string][]byte, key string) (klient.Client, error) {
-	configBytes, exists := cd[key]
-	if !exists {
-		return nil, errors.Errorf("no connection details for key %q", key)
+func newClientFromConnectionDetails(ctx context.Context, cd map[string][]byte, key string, opts ...Option) (klient.Client, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
 	}
-	return klient.NewClientFromConfigBytes(configBytes)
+	var cfg *rest.Config
+	var err error
+	if o.keys != nil {
+		cfg, err = restConfigFromKeys(cd, *o.keys, o)
+	} else {
+		cfg, err = restConfigFromKubeconfig(cd, key, o)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !o.skipConnectivity {
+		if err := checkConnectivity(ctx, cfg, o.clientOpts); err != nil {
+			return nil, err
+		}
+	}
+	return klient.NewClient(cfg, append([]klient.ClientOption{klient.WithEKSPreset()}, o.clientOpts...)...)
 }
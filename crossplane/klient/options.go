@@ -0,0 +1,192 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package klient
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/klient"
+)
+
+// ConnectionDetailsKeys are the keys of connection details that contain the
+// parts of a kubeconfig. Empty keys are not used.
+type ConnectionDetailsKeys struct {
+	Endpoint   string
+	CA         string
+	Token      string
+	ClientCert string
+	ClientKey  string
+}
+
+// options of a client created from connection details.
+type options struct {
+	context          string
+	server           string
+	keys             *ConnectionDetailsKeys
+	clientOpts       []klient.ClientOption
+	skipConnectivity bool
+}
+
+// Option modifies how a client is created from connection details. By
+// default the current context of the kubeconfig is used and the constructors
+// verify that the cluster can be reached before the client is returned.
+type Option func(o *options)
+
+// WithContext uses the context with the given name of the kubeconfig instead
+// of its current context.
+func WithContext(name string) Option {
+	return func(o *options) {
+		o.context = name
+	}
+}
+
+// WithServer overrides the server URL of the kubeconfig, e.g. to connect
+// through a local port-forward.
+func WithServer(url string) Option {
+	return func(o *options) {
+		o.server = url
+	}
+}
+
+// FromKeys builds the client from separate connection details keys instead
+// of a kubeconfig. The connection details key passed to the constructor is
+// ignored.
+func FromKeys(keys ConnectionDetailsKeys) Option {
+	return func(o *options) {
+		o.keys = &keys
+	}
+}
+
+// WithClientOptions are passed to klient.NewClient after
+// klient.WithEKSPreset.
+func WithClientOptions(opts ...klient.ClientOption) Option {
+	return func(o *options) {
+		o.clientOpts = append(o.clientOpts, opts...)
+	}
+}
+
+// WithoutConnectivityCheck skips the discovery call that verifies that the
+// cluster can be reached before the client is returned.
+func WithoutConnectivityCheck() Option {
+	return func(o *options) {
+		o.skipConnectivity = true
+	}
+}
+
+// restConfigFromKubeconfig builds a rest config from the kubeconfig stored
+// at key.
+func restConfigFromKubeconfig(cd map[string][]byte, key string, o *options) (*rest.Config, error) {
+	configBytes, exists := cd[key]
+	if !exists {
+		return nil, errors.Errorf("no connection details for key %q", key)
+	}
+	apiConfig, err := clientcmd.Load(configBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load kubeconfig")
+	}
+	overrides := &clientcmd.ConfigOverrides{}
+	if o.server != "" {
+		overrides.ClusterInfo.Server = o.server
+		// keep verifying the serving certificate against the original host
+		contextName := o.context
+		if contextName == "" {
+			contextName = apiConfig.CurrentContext
+		}
+		if kubeContext, ok := apiConfig.Contexts[contextName]; ok {
+			if cluster, ok := apiConfig.Clusters[kubeContext.Cluster]; ok {
+				overrides.ClusterInfo.TLSServerName = cluster.TLSServerName
+				if overrides.ClusterInfo.TLSServerName == "" {
+					overrides.ClusterInfo.TLSServerName = hostname(cluster.Server)
+				}
+			}
+		}
+	}
+	cfg, err := clientcmd.NewNonInteractiveClientConfig(*apiConfig, o.context, overrides, nil).ClientConfig()
+	return cfg, errors.Wrap(err, "cannot create client config from kubeconfig")
+}
+
+// restConfigFromKeys builds a rest config from separate connection details
+// keys.
+func restConfigFromKeys(cd map[string][]byte, keys ConnectionDetailsKeys, o *options) (*rest.Config, error) {
+	endpoint := string(cd[keys.Endpoint])
+	if endpoint == "" {
+		return nil, errors.Errorf("no connection details for key %q", keys.Endpoint)
+	}
+	cfg := &rest.Config{Host: endpoint}
+	if o.server != "" {
+		cfg.Host = o.server
+		// keep verifying the serving certificate against the original host
+		cfg.TLSClientConfig.ServerName = hostname(endpoint)
+	}
+	for _, v := range []struct {
+		key    string
+		target *[]byte
+	}{
+		{keys.CA, &cfg.CAData},
+		{keys.ClientCert, &cfg.CertData},
+		{keys.ClientKey, &cfg.KeyData},
+	} {
+		if v.key == "" {
+			continue
+		}
+		value, exists := cd[v.key]
+		if !exists {
+			return nil, errors.Errorf("no connection details for key %q", v.key)
+		}
+		*v.target = value
+	}
+	if keys.Token != "" {
+		token, exists := cd[keys.Token]
+		if !exists {
+			return nil, errors.Errorf("no connection details for key %q", keys.Token)
+		}
+		cfg.BearerToken = string(token)
+	}
+	return cfg, nil
+}
+
+// hostname returns the host of server without port. server may omit the
+// scheme.
+func hostname(server string) string {
+	if !strings.Contains(server, "://") {
+		server = "https://" + server
+	}
+	u, err := url.Parse(server)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// connectivityCheckTimeout limits the discovery call that verifies that a
+// cluster can be reached.
+const connectivityCheckTimeout = 10 * time.Second
+
+// checkConnectivity verifies that the cluster of cfg can be reached using a
+// client with clientOpts. It does not retry and gives up after
+// connectivityCheckTimeout.
+func checkConnectivity(ctx context.Context, cfg *rest.Config, clientOpts []klient.ClientOption) error {
+	opts := append([]klient.ClientOption{klient.WithEKSPreset()}, clientOpts...)
+	opts = append(opts, klient.WithRetryPolicy(klient.RetryPolicy{}), klient.WithTimeout(connectivityCheckTimeout))
+	kube, err := klient.NewClient(cfg, opts...)
+	if err != nil {
+		return err
+	}
+	dc, err := discovery.NewDiscoveryClientForConfig(kube.RESTConfig())
+	if err != nil {
+		return errors.Wrap(err, "cannot create discovery client")
+	}
+	ctx, cancel := context.WithTimeout(ctx, connectivityCheckTimeout)
+	defer cancel()
+	err = dc.RESTClient().Get().AbsPath("/version").Do(ctx).Error()
+	return errors.Wrap(err, "cannot connect to cluster")
+}
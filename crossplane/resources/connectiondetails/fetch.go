@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package connectiondetails
+
+import (
+	"context"
+
+	xpclaim "github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+	xpcomposed "github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composed"
+	xpcomposite "github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/composite"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/crossplane/resources"
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/klient"
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/resources/secret"
+)
+
+// FetchFromClaimObject fetches the connection details exported as secret by a
+// crossplane claim and validates them against rules. Unlike FromClaimObject
+// it returns an error that describes the missing step if the claim does not
+// reference a secret yet, the secret does not exist or a rule is violated.
+func FetchFromClaimObject(ctx context.Context, kube klient.Client, claimObj client.Object, rules ...Rule) (ConnectionDetails, error) {
+	claim := xpclaim.New(xpclaim.WithGroupVersionKind(claimObj.GetObjectKind().GroupVersionKind()))
+	if err := klient.Get(ctx, kube, claimObj.GetName(), claimObj.GetNamespace(), claim); err != nil {
+		return nil, errors.Wrap(err, "cannot get claim")
+	}
+	ref := claim.GetWriteConnectionSecretToReference()
+	if ref == nil {
+		return nil, errors.New("claim does not reference a connection secret yet")
+	}
+	return fetchConnectionDetails(ctx, kube, ref.Name, claim.GetNamespace(), rules)
+}
+
+// FetchFromCompositeObject fetches the connection details exported as secret
+// by a crossplane composite and validates them against rules. Unlike
+// FromCompositeObject it returns an error that describes the missing step if
+// the composite does not reference a secret yet, the secret does not exist or
+// a rule is violated.
+func FetchFromCompositeObject(ctx context.Context, kube klient.Client, compositeObj client.Object, rules ...Rule) (ConnectionDetails, error) {
+	composite := xpcomposite.New(xpcomposite.WithGroupVersionKind(compositeObj.GetObjectKind().GroupVersionKind()))
+	if err := klient.Get(ctx, kube, compositeObj.GetName(), compositeObj.GetNamespace(), composite); err != nil {
+		return nil, errors.Wrap(err, "cannot get composite")
+	}
+	ref := composite.GetWriteConnectionSecretToReference()
+	if ref == nil {
+		return nil, errors.New("composite does not reference a connection secret yet")
+	}
+	return fetchConnectionDetails(ctx, kube, ref.Name, ref.Namespace, rules)
+}
+
+// FetchFromComposedByClaim fetches the connection details exported as secret
+// by a crossplane composed resource that is referenced by a claim and
+// validates them against rules. Unlike FromComposedByClaim it returns an
+// error that describes the missing step if the composed resource does not
+// reference a secret yet, the secret does not exist or a rule is violated.
+func FetchFromComposedByClaim(ctx context.Context, kube klient.Client, claim client.Object, resourceName string, resourceGVK schema.GroupVersionKind, rules ...Rule) (ConnectionDetails, error) {
+	composed := &xpcomposed.Unstructured{}
+	composed.SetGroupVersionKind(resourceGVK)
+	if err := resources.GetComposedFromClaim(ctx, kube, claim, resourceName, composed); err != nil {
+		return nil, errors.Wrapf(err, "cannot get composed resource %q", resourceName)
+	}
+	ref := composed.GetWriteConnectionSecretToReference()
+	if ref == nil {
+		return nil, errors.Errorf("composed resource %q does not reference a connection secret yet", resourceName)
+	}
+	return fetchConnectionDetails(ctx, kube, ref.Name, ref.Namespace, rules)
+}
+
+func fetchConnectionDetails(ctx context.Context, kube klient.Client, secretName, secretNamespace string, rules []Rule) (ConnectionDetails, error) {
+	data, err := secret.GetSecretData(ctx, kube, secretName, secretNamespace)
+	if kerrors.IsNotFound(err) {
+		return nil, errors.Errorf("connection secret %s/%s does not exist yet", secretNamespace, secretName)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get connection secret %s/%s", secretNamespace, secretName)
+	}
+	cd := ConnectionDetails(data)
+	if err := cd.Validate(rules...); err != nil {
+		return nil, errors.Wrapf(err, "connection secret %s/%s is invalid", secretNamespace, secretName)
+	}
+	return cd, nil
+}
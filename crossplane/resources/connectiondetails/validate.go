@@ -0,0 +1,172 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package connectiondetails
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Rule validates connection details and returns an error describing the
+// violation.
+type Rule func(cd ConnectionDetails) error
+
+// Validate cd against all rules and return an aggregated error of all
+// violations or nil if all rules are satisfied.
+func (cd ConnectionDetails) Validate(rules ...Rule) error {
+	errs := make([]error, 0, len(rules))
+	for _, rule := range rules {
+		if err := rule(cd); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return kerrors.NewAggregate(errs)
+}
+
+// RequireKeys requires that all keys exist and have non-empty values.
+func RequireKeys(keys ...string) Rule {
+	return func(cd ConnectionDetails) error {
+		missing := []string{}
+		for _, key := range keys {
+			if len(cd[key]) == 0 {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) > 0 {
+			return errors.Errorf("missing or empty keys %q", missing)
+		}
+		return nil
+	}
+}
+
+// MatchRegex requires that the value of key matches the regular expression
+// pattern. It panics if pattern cannot be compiled.
+func MatchRegex(key, pattern string) Rule {
+	re := regexp.MustCompile(pattern)
+	return valueRule(key, func(value []byte) error {
+		if !re.Match(value) {
+			return errors.Errorf("does not match %q", pattern)
+		}
+		return nil
+	})
+}
+
+// IsURL requires that the value of key is an absolute URL with a host.
+func IsURL(key string) Rule {
+	return valueRule(key, func(value []byte) error {
+		u, err := url.Parse(string(value))
+		if err != nil {
+			return errors.Wrap(err, "cannot parse URL")
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return errors.New("URL has no scheme or host")
+		}
+		return nil
+	})
+}
+
+// HasPort requires that the value of key is the given port, either as a plain
+// number, as host:port or as URL with an explicit port.
+func HasPort(key string, port int) Rule {
+	return valueRule(key, func(value []byte) error {
+		got, err := parsePort(string(value))
+		if err != nil {
+			return err
+		}
+		if got != port {
+			return errors.Errorf("port is %d, expected %d", got, port)
+		}
+		return nil
+	})
+}
+
+func parsePort(s string) (int, error) {
+	if p, err := strconv.Atoi(s); err == nil {
+		return p, nil
+	}
+	if u, err := url.Parse(s); err == nil && u.Port() != "" {
+		return strconv.Atoi(u.Port())
+	}
+	if _, p, err := net.SplitHostPort(s); err == nil {
+		return strconv.Atoi(p)
+	}
+	return 0, errors.Errorf("cannot determine port of %q", s)
+}
+
+// IsPEMCertificateChain requires that the value of key contains one or more
+// PEM encoded x509 certificates that are valid for at least minValidity.
+func IsPEMCertificateChain(key string, minValidity time.Duration) Rule {
+	return valueRule(key, func(value []byte) error {
+		now := time.Now()
+		count := 0
+		for rest := value; ; {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			count++
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return errors.Wrapf(err, "cannot parse certificate %d", count)
+			}
+			if now.Before(cert.NotBefore) {
+				return errors.Errorf("certificate %q is not valid before %s", cert.Subject.String(), cert.NotBefore.Format(time.RFC3339))
+			}
+			if now.Add(minValidity).After(cert.NotAfter) {
+				return errors.Errorf("certificate %q expires at %s", cert.Subject.String(), cert.NotAfter.Format(time.RFC3339))
+			}
+		}
+		if count == 0 {
+			return errors.New("no PEM encoded certificate found")
+		}
+		return nil
+	})
+}
+
+// IsKubeconfig requires that the value of key is a valid kubeconfig.
+func IsKubeconfig(key string) Rule {
+	return valueRule(key, func(value []byte) error {
+		cfg, err := clientcmd.Load(value)
+		if err != nil {
+			return errors.Wrap(err, "cannot load kubeconfig")
+		}
+		return errors.Wrap(clientcmd.Validate(*cfg), "invalid kubeconfig")
+	})
+}
+
+// IsJSON requires that the value of key is valid JSON.
+func IsJSON(key string) Rule {
+	return valueRule(key, func(value []byte) error {
+		if !json.Valid(value) {
+			return errors.New("invalid JSON")
+		}
+		return nil
+	})
+}
+
+// valueRule applies check to the value of key. Missing keys are reported as
+// violation.
+func valueRule(key string, check func(value []byte) error) Rule {
+	return func(cd ConnectionDetails) error {
+		value, exists := cd[key]
+		if !exists {
+			return errors.Errorf("key %q: missing", key)
+		}
+		return errors.Wrapf(check(value), "key %q", key)
+	}
+}
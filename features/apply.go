@@ -27,10 +27,12 @@ import (
 //
 // mod is an optional function that can be given to modify the
 // object before applying it.
+//
+// The object is recorded in the context and deleted by Teardown.
 func ApplyObject(o client.Object, mods ...func(o client.Object)) features.Func {
-	return Assess(func(ctx context.Context, t *testing.T, cfg *envconf.Config) error {
-		return applyObject(ctx, t, cfg, cfg.Client().Resources().GetControllerRuntimeClient(), o, mods...)
-	})
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		return applyAndTrackObject(ctx, t, cfg, cfg.Client().Resources().GetControllerRuntimeClient(), o, mods...)
+	}
 }
 
 // ApplyObjectWithClient returns a [sigs.k8s.io/e2e-framework/pkg/features.Func] that
@@ -38,13 +40,33 @@ func ApplyObject(o client.Object, mods ...func(o client.Object)) features.Func {
 //
 // It automatically sets the namespace of o to the preconfigured test namespace
 // if o does not already have a namespace set.
+//
+// The object is recorded in the context and deleted by Teardown.
 func ApplyObjectWithClient(o client.Object, kube klient.Client, mods ...func(o client.Object)) features.Func {
-	return Assess(func(ctx context.Context, t *testing.T, cfg *envconf.Config) error {
-		return applyObject(ctx, t, cfg, kube.Resources().GetControllerRuntimeClient(), o, mods...)
-	})
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		return applyAndTrackObject(ctx, t, cfg, kube.Resources().GetControllerRuntimeClient(), o, mods...)
+	}
+}
+
+func applyAndTrackObject(ctx context.Context, t *testing.T, cfg *envconf.Config, kube client.Client, o client.Object, mods ...func(o client.Object)) context.Context {
+	ctx, tracker := contextWithCleanupTracker(ctx)
+	if err := applyObject(ctx, t, cfg, kube, o, mods...); err != nil {
+		t.Errorf("assess failed: %s\n", err.Error())
+		return ctx
+	}
+	tracker.track(kube, o)
+	return ctx
 }
 
 func applyObject(ctx context.Context, t *testing.T, cfg *envconf.Config, kube client.Client, o client.Object, mods ...func(o client.Object)) error {
+	if err := prepareApplyObject(cfg, kube, o, mods...); err != nil {
+		return err
+	}
+	return errors.Wrap(applyObjectSSA(ctx, kube, o, fieldOwnerFromT(t)), "cannot apply object")
+}
+
+// prepareApplyObject prepares o for server-side apply and applies mods.
+func prepareApplyObject(cfg *envconf.Config, kube client.Client, o client.Object, mods ...func(o client.Object)) error {
 	// remove any managed fields in request for SSA
 	o.SetManagedFields(nil)
 	o.SetResourceVersion("")
@@ -68,8 +90,7 @@ func applyObject(ctx context.Context, t *testing.T, cfg *envconf.Config, kube cl
 	for _, mod := range mods {
 		mod(o)
 	}
-
-	return errors.Wrap(applyObjectSSA(ctx, kube, o, fieldOwnerFromT(t)), "cannot apply object")
+	return nil
 }
 
 func fieldOwnerFromT(t *testing.T) string {
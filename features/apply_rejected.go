@@ -0,0 +1,112 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package features
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+)
+
+// RejectionExpectation describes how the API server is expected to reject an
+// object. Empty fields match any rejection.
+type RejectionExpectation struct {
+	// Reason of the returned status, e.g. metav1.StatusReasonInvalid for
+	// schema and CEL validation errors or metav1.StatusReasonForbidden for
+	// denials of validating webhooks.
+	Reason metav1.StatusReason
+	// Fields that must be reported as causes of the rejection, e.g.
+	// "spec.forProvider.region".
+	Fields []string
+	// Message must match the message of the returned status.
+	Message *regexp.Regexp
+}
+
+// mismatches returns a description of every part of the expectation that is
+// not met by err.
+func (e RejectionExpectation) mismatches(err error) string {
+	buf := &bytes.Buffer{}
+	var st metav1.Status
+	var status kerrors.APIStatus
+	if errors.As(err, &status) {
+		st = status.Status()
+	}
+	if e.Reason != "" && st.Reason != e.Reason {
+		fmt.Fprintf(buf, "  reason: want %s, got %s\n", e.Reason, st.Reason)
+	}
+	var fields []string
+	if st.Details != nil {
+		for _, c := range st.Details.Causes {
+			fields = append(fields, c.Field)
+		}
+	}
+	for _, f := range e.Fields {
+		if !slices.Contains(fields, f) {
+			fmt.Fprintf(buf, "  cause: want field %q, got %q\n", f, fields)
+		}
+	}
+	if e.Message != nil && !e.Message.MatchString(err.Error()) {
+		fmt.Fprintf(buf, "  message: want match of %q, got %q\n", e.Message.String(), err.Error())
+	}
+	return buf.String()
+}
+
+// ExpectApplyRejected returns a [sigs.k8s.io/e2e-framework/pkg/features.Func]
+// that applies o using server-side apply and requires the API server to
+// reject it as described by expected. It is meant to test validating
+// webhooks, CEL validation rules and schemas of CRDs.
+//
+// The test fails if o is accepted. An accepted object is deleted again if it
+// did not exist before, so updates of existing objects can be tested as well.
+//
+// It automatically sets the namespace of o to the preconfigured test namespace
+// if o does not already have a namespace set.
+func ExpectApplyRejected(o client.Object, expected RejectionExpectation, mods ...func(o client.Object)) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		kube := cfg.Client().Resources().GetControllerRuntimeClient()
+		if err := prepareApplyObject(cfg, kube, o, mods...); err != nil {
+			t.Errorf("assess failed: %s\n", err.Error())
+			return ctx
+		}
+
+		// Remember whether the object exists to only delete objects that are
+		// created by an accepted apply.
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(o.GetObjectKind().GroupVersionKind())
+		err := kube.Get(ctx, client.ObjectKeyFromObject(o), existing)
+		if client.IgnoreNotFound(err) != nil {
+			t.Errorf("cannot check whether object exists: %s\n", err.Error())
+			return ctx
+		}
+		existed := err == nil
+
+		// The apply is not retried as rejections are expected.
+		err = kube.Patch(ctx, o, client.Apply, client.FieldOwner(fieldOwnerFromT(t)), client.ForceOwnership)
+		if err == nil {
+			t.Errorf("%s %s was accepted but expected to be rejected\n", o.GetObjectKind().GroupVersionKind().Kind, client.ObjectKeyFromObject(o))
+			if existed {
+				return ctx // never delete objects this step has not created
+			}
+			if err := kube.Delete(ctx, o); client.IgnoreNotFound(err) != nil {
+				t.Errorf("cannot delete accepted object: %s\n", err.Error())
+			}
+			return ctx
+		}
+		if diff := expected.mismatches(err); diff != "" {
+			t.Errorf("apply was rejected with an unexpected error: %s\n%s", err.Error(), diff)
+		}
+		return ctx
+	}
+}
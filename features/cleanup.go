@@ -0,0 +1,185 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package features
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/e2e-framework/klient/wait"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+)
+
+// trackedObject is an object applied by a feature along with the client that
+// applied it.
+type trackedObject struct {
+	object client.Object
+	kube   client.Client
+}
+
+func (o trackedObject) String() string {
+	return fmt.Sprintf("%s %s", o.object.GetObjectKind().GroupVersionKind().Kind, client.ObjectKeyFromObject(o.object))
+}
+
+// CleanupTracker records the objects applied by a feature so they can be
+// deleted by Teardown. It is safe for concurrent use.
+type CleanupTracker struct {
+	mu      sync.Mutex
+	objects []trackedObject
+}
+
+// track records o unless it has already been recorded.
+func (c *CleanupTracker) track(kube client.Client, o client.Object) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	gvk := o.GetObjectKind().GroupVersionKind()
+	for _, tracked := range c.objects {
+		if tracked.object.GetObjectKind().GroupVersionKind() == gvk && client.ObjectKeyFromObject(tracked.object) == client.ObjectKeyFromObject(o) {
+			return
+		}
+	}
+	c.objects = append(c.objects, trackedObject{object: o, kube: kube})
+}
+
+// reversed returns the recorded objects in reverse order.
+func (c *CleanupTracker) reversed() []trackedObject {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]trackedObject, 0, len(c.objects))
+	for i := len(c.objects) - 1; i >= 0; i-- {
+		out = append(out, c.objects[i])
+	}
+	return out
+}
+
+type cleanupTrackerContextKey struct{}
+
+// CleanupTrackerFromContext returns the tracker stored in ctx or nil if no
+// object has been applied yet.
+func CleanupTrackerFromContext(ctx context.Context) *CleanupTracker {
+	c, _ := ctx.Value(cleanupTrackerContextKey{}).(*CleanupTracker)
+	return c
+}
+
+// contextWithCleanupTracker returns ctx and the tracker stored in it. A new
+// tracker is added to the context if there is none.
+func contextWithCleanupTracker(ctx context.Context) (context.Context, *CleanupTracker) {
+	if c := CleanupTrackerFromContext(ctx); c != nil {
+		return ctx, c
+	}
+	c := &CleanupTracker{}
+	return context.WithValue(ctx, cleanupTrackerContextKey{}, c), c
+}
+
+// CleanupConfig specifies how Teardown deletes the tracked objects.
+type CleanupConfig struct {
+	keepOnFailure bool
+	waitOpts      []WaitOption
+}
+
+// CleanupOption modifies a CleanupConfig.
+type CleanupOption func(c *CleanupConfig)
+
+// CleanupKeepOnFailure keeps all tracked objects if the feature has failed to
+// allow debugging.
+func CleanupKeepOnFailure() CleanupOption {
+	return func(c *CleanupConfig) {
+		c.keepOnFailure = true
+	}
+}
+
+// CleanupWithWaitOptions configures how Teardown waits for the deleted
+// objects to be gone. A timeout set with WaitWithTimeout takes precedence over
+// the timeout passed to Teardown.
+func CleanupWithWaitOptions(opts ...WaitOption) CleanupOption {
+	return func(c *CleanupConfig) {
+		c.waitOpts = append(c.waitOpts, opts...)
+	}
+}
+
+// Teardown returns a [sigs.k8s.io/e2e-framework/pkg/features.Func] that
+// deletes all objects applied by ApplyObject and ApplyObjectWithClient in the
+// same feature. Objects are deleted in reverse order using foreground
+// cascading delete. Each object is gone before the next one is deleted.
+// timeout applies to each object, unless it is overridden by WaitWithTimeout
+// in CleanupWithWaitOptions. It does not cancel if the timeout duration is
+// zero.
+//
+// It is meant to be used as teardown step of a feature.
+func Teardown(timeout time.Duration, opts ...CleanupOption) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		tracker := CleanupTrackerFromContext(ctx)
+		if tracker == nil {
+			return ctx
+		}
+		cleanupCfg := CleanupConfig{}
+		for _, o := range opts {
+			o(&cleanupCfg)
+		}
+		objects := tracker.reversed()
+		if cleanupCfg.keepOnFailure && t.Failed() {
+			names := make([]string, len(objects))
+			for i, o := range objects {
+				names[i] = o.String()
+			}
+			t.Logf("feature failed, keeping objects: %s\n", strings.Join(names, ", "))
+			return ctx
+		}
+
+		waitCfg := WaitConfig{}
+		waitCfg.Apply(cleanupCfg.waitOpts)
+		objectTimeout := timeout
+		if waitCfg.timeout > 0 {
+			objectTimeout = waitCfg.timeout
+		}
+
+		for _, o := range objects {
+			err := o.kube.Delete(ctx, o.object, &client.DeleteOptions{
+				PropagationPolicy: ptr.To(metav1.DeletePropagationForeground),
+			})
+			if kerrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				t.Errorf("cannot delete %s: %s\n", o, err.Error())
+				continue
+			}
+			waitCtx, cancel := contextWithOptionalTimeout(ctx, objectTimeout)
+			err = wait.For(isObjectDeleted(o), append(slices.Clone(waitCfg.waitForOptions), wait.WithContext(waitCtx))...)
+			cancel()
+			if err != nil {
+				t.Errorf("failed waiting for %s to become deleted: %s\n", o, err.Error())
+			}
+		}
+		return ctx
+	}
+}
+
+func contextWithOptionalTimeout(parentCtx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		return parentCtx, func() {}
+	}
+	return context.WithTimeout(parentCtx, timeout)
+}
+
+func isObjectDeleted(o trackedObject) func(ctx context.Context) (bool, error) {
+	return func(ctx context.Context) (bool, error) {
+		current := o.object.DeepCopyObject().(client.Object)
+		err := o.kube.Get(ctx, client.ObjectKeyFromObject(o.object), current)
+		if kerrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, nil //nolint:nilerr // retry until the timeout is reached
+	}
+}
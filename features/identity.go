@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package features
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/klient"
+)
+
+// IdentityExpectation returns an error if the identity does not match.
+type IdentityExpectation func(u *klient.UserInfo) error
+
+// IdentityIsUser expects the identity to have the given username.
+func IdentityIsUser(username string) IdentityExpectation {
+	return func(u *klient.UserInfo) error {
+		if u.Username != username {
+			return errors.Errorf("want username %q, got %q", username, u.Username)
+		}
+		return nil
+	}
+}
+
+// IdentityIsServiceAccount expects the identity to be the given service
+// account.
+func IdentityIsServiceAccount(namespace, name string) IdentityExpectation {
+	return IdentityIsUser(fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name))
+}
+
+// IdentityInGroups expects the identity to be a member of all groups.
+func IdentityInGroups(groups ...string) IdentityExpectation {
+	return func(u *klient.UserInfo) error {
+		for _, g := range groups {
+			if !slices.Contains(u.Groups, g) {
+				return errors.Errorf("want group %q, got %q", g, u.Groups)
+			}
+		}
+		return nil
+	}
+}
+
+// ClientFunc returns the client whose identity is asserted. It is called
+// when the step is executed, e.g. to create a client from connection details
+// of a claim.
+type ClientFunc func(ctx context.Context, cfg *envconf.Config) (klient.Client, error)
+
+// AssertIdentity returns a [sigs.k8s.io/e2e-framework/pkg/features.Func]
+// that verifies that kube authenticates with the expected identity.
+func AssertIdentity(kube klient.Client, expectations ...IdentityExpectation) features.Func {
+	return AssertIdentityOf(func(context.Context, *envconf.Config) (klient.Client, error) {
+		return kube, nil
+	}, expectations...)
+}
+
+// AssertIdentityOf returns a [sigs.k8s.io/e2e-framework/pkg/features.Func]
+// that verifies that the client returned by newClient authenticates with the
+// expected identity. It fails if the API server cannot report the identity.
+func AssertIdentityOf(newClient ClientFunc, expectations ...IdentityExpectation) features.Func {
+	return Assess(func(ctx context.Context, t *testing.T, cfg *envconf.Config) error {
+		kube, err := newClient(ctx, cfg)
+		if err != nil {
+			return errors.Wrap(err, "cannot create client")
+		}
+		u, err := klient.WhoAmI(ctx, kube)
+		if err != nil {
+			return errors.Wrap(err, "cannot determine identity")
+		}
+		if !u.Verified {
+			return errors.Errorf("identity %q is derived from the client credentials and cannot be verified without the SelfSubjectReview API", u.Username)
+		}
+		for _, e := range expectations {
+			if err := e(u); err != nil {
+				return errors.Wrap(err, "unexpected identity")
+			}
+		}
+		return nil
+	})
+}
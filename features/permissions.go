@@ -0,0 +1,128 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package features
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"text/tabwriter"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"sigs.k8s.io/e2e-framework/pkg/envconf"
+	"sigs.k8s.io/e2e-framework/pkg/features"
+
+	"github.com/dbinfrago/kubernetes-e2e-test-framework/klient"
+)
+
+// Permission is a row of a permission matrix. It describes whether an action
+// is expected to be allowed.
+type Permission struct {
+	Verb        string
+	Group       string
+	Resource    string
+	Subresource string
+	// Namespace of the action. It is empty for cluster-scoped resources and
+	// actions across all namespaces.
+	Namespace string
+	Name      string
+	Allowed   bool
+}
+
+func (p Permission) attributes() authorizationv1.ResourceAttributes {
+	return authorizationv1.ResourceAttributes{
+		Verb:        p.Verb,
+		Group:       p.Group,
+		Resource:    p.Resource,
+		Subresource: p.Subresource,
+		Namespace:   p.Namespace,
+		Name:        p.Name,
+	}
+}
+
+func (p Permission) resource() string {
+	r := p.Resource
+	if p.Group != "" {
+		r += "." + p.Group
+	}
+	if p.Subresource != "" {
+		r += "/" + p.Subresource
+	}
+	return r
+}
+
+// AssessPermissions returns a [sigs.k8s.io/e2e-framework/pkg/features.Func]
+// that checks the permissions of the user of kube using
+// SelfSubjectAccessReviews. Use klient.NewImpersonatingClient to check the
+// permissions of another user.
+func AssessPermissions(kube klient.Client, permissions ...Permission) features.Func {
+	return assessPermissions(permissions, func(ctx context.Context, _ *envconf.Config, p Permission) (bool, error) {
+		return klient.CanI(ctx, kube, p.attributes())
+	})
+}
+
+// AssessSubjectPermissions returns a
+// [sigs.k8s.io/e2e-framework/pkg/features.Func] that checks the permissions
+// of the given user and groups using SubjectAccessReviews.
+func AssessSubjectPermissions(username string, groups []string, permissions ...Permission) features.Func {
+	return assessPermissions(permissions, func(ctx context.Context, cfg *envconf.Config, p Permission) (bool, error) {
+		return klient.SubjectCan(ctx, cfg.Client(), username, groups, p.attributes())
+	})
+}
+
+func assessPermissions(permissions []Permission, review func(ctx context.Context, cfg *envconf.Config, p Permission) (bool, error)) features.Func {
+	return func(ctx context.Context, t *testing.T, cfg *envconf.Config) context.Context {
+		got := make([]*bool, len(permissions))
+		mismatch := false
+		for i, p := range permissions {
+			allowed, err := review(ctx, cfg, p)
+			if err != nil {
+				t.Errorf("cannot review %s %s: %s\n", p.Verb, p.resource(), err.Error())
+				mismatch = true
+				continue
+			}
+			got[i] = &allowed
+			mismatch = mismatch || allowed != p.Allowed
+		}
+		if mismatch {
+			t.Errorf("permissions do not match:\n%s", permissionMatrixDiff(permissions, got))
+		}
+		return ctx
+	}
+}
+
+// permissionMatrixDiff renders all permissions as a table. Rows whose review
+// result differs from the expectation are marked with "!".
+func permissionMatrixDiff(permissions []Permission, got []*bool) string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tVERB\tRESOURCE\tNAMESPACE\tNAME\tWANT\tGOT")
+	for i, p := range permissions {
+		marker, result := " ", "error"
+		if got[i] != nil {
+			result = allowedString(*got[i])
+		}
+		if got[i] == nil || *got[i] != p.Allowed {
+			marker = "!"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", marker, p.Verb, p.resource(), orDash(p.Namespace), orDash(p.Name), allowedString(p.Allowed), result)
+	}
+	_ = w.Flush()
+	return buf.String()
+}
+
+func allowedString(allowed bool) string {
+	if allowed {
+		return "allowed"
+	}
+	return "denied"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
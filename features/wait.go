@@ -18,6 +18,9 @@ import (
 // WaitConfig specifies how a waiting assessment should be executed.
 type WaitConfig struct {
 	waitForOptions []wait.Option
+	// timeout is the last timeout set by WaitWithTimeout. Helpers that wait
+	// with a context of their own use it as deadline of that context.
+	timeout time.Duration
 }
 
 // Apply the given waitopts.
@@ -41,6 +44,7 @@ func WaitWithIntervall(intervall time.Duration) WaitOption {
 func WaitWithTimeout(timeout time.Duration) WaitOption {
 	return func(c *WaitConfig) {
 		c.waitForOptions = append(c.waitForOptions, wait.WithTimeout(timeout))
+		c.timeout = timeout
 	}
 }
 
@@ -5,25 +5,170 @@ package klient
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"strings"
 
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
 	"sigs.k8s.io/e2e-framework/klient"
 )
 
+// selfSubjectReviewAPIVersions in the order they are tried.
+var selfSubjectReviewAPIVersions = []string{
+	"authentication.k8s.io/v1",
+	"authentication.k8s.io/v1beta1",
+	"authentication.k8s.io/v1alpha1",
+}
+
+// UserInfo describes the identity the API server authenticates a client as.
+type UserInfo struct {
+	Username string              `json:"username"`
+	UID      string              `json:"uid"`
+	Groups   []string            `json:"groups"`
+	Extra    map[string][]string `json:"extra"`
+
+	// Verified is true if the identity has been reported by the API server.
+	// It is false if the identity is derived from the credentials of the
+	// client, which are only known to be accepted by the API server.
+	Verified bool `json:"-"`
+}
+
 // GetKubeUsername returns the actual kube user name in the same way
 // "kubectl auth whoami" does it.
 func GetKubeUsername(ctx context.Context, kube klient.Client) (string, error) {
-	// We have to use unstructured here because the API types are not available
-	// in this version of client-go (or are lacking the respective properties).
-	ssar := &unstructured.Unstructured{
-		Object: map[string]interface{}{
-			"apiVersion": "authentication.k8s.io/v1",
-			"kind":       "SelfSubjectReview",
-		},
-	}
-	if err := kube.Resources().GetControllerRuntimeClient().Create(ctx, ssar); err != nil {
+	u, err := WhoAmI(ctx, kube)
+	if err != nil {
 		return "", err
 	}
-	username, _, _ := unstructured.NestedString(ssar.Object, "status", "userInfo", "username")
-	return username, nil
+	return u.Username, nil
+}
+
+// WhoAmI returns the identity of kube in the same way "kubectl auth whoami"
+// does it. If no version of the SelfSubjectReview API is available, the
+// identity is derived from the credentials of the client instead after
+// verifying that the API server accepts them. In this case Verified is false,
+// UID and Extra are empty and Groups may be incomplete.
+func WhoAmI(ctx context.Context, kube klient.Client) (*UserInfo, error) {
+	for _, apiVersion := range selfSubjectReviewAPIVersions {
+		// We have to use unstructured here because the API types are not available
+		// in this version of client-go (or are lacking the respective properties).
+		ssr := &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"apiVersion": apiVersion,
+				"kind":       "SelfSubjectReview",
+			},
+		}
+		err := kube.Resources().GetControllerRuntimeClient().Create(ctx, ssr)
+		if meta.IsNoMatchError(err) {
+			continue // API version is not served
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot create SelfSubjectReview")
+		}
+		return userInfoFromSelfSubjectReview(ssr)
+	}
+	// An authenticated request fails if the API server rejects the
+	// credentials. Any user is allowed to create SelfSubjectAccessReviews.
+	if _, err := CanI(ctx, kube, authorizationv1.ResourceAttributes{Verb: "get", Resource: "namespaces"}); err != nil {
+		return nil, errors.Wrap(err, "cannot verify credentials")
+	}
+	return userInfoFromConfig(kube.RESTConfig())
+}
+
+func userInfoFromSelfSubjectReview(ssr *unstructured.Unstructured) (*UserInfo, error) {
+	raw, found, err := unstructured.NestedMap(ssr.Object, "status", "userInfo")
+	if err != nil || !found {
+		return nil, errors.New("SelfSubjectReview does not contain user info")
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal user info")
+	}
+	u := &UserInfo{Verified: true}
+	if err := json.Unmarshal(b, u); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal user info")
+	}
+	if u.Username == "" {
+		return nil, errors.New("SelfSubjectReview does not contain a username")
+	}
+	return u, nil
+}
+
+// userInfoFromConfig derives the identity from the impersonation settings,
+// the client certificate or the service account token of cfg.
+func userInfoFromConfig(cfg *rest.Config) (*UserInfo, error) {
+	if cfg.Impersonate.UserName != "" {
+		return &UserInfo{
+			Username: cfg.Impersonate.UserName,
+			UID:      cfg.Impersonate.UID,
+			Groups:   cfg.Impersonate.Groups,
+			Extra:    cfg.Impersonate.Extra,
+		}, nil
+	}
+	certData := cfg.CertData
+	if len(certData) == 0 && cfg.CertFile != "" {
+		b, err := os.ReadFile(cfg.CertFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read client certificate")
+		}
+		certData = b
+	}
+	if block, _ := pem.Decode(certData); block != nil {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot parse client certificate")
+		}
+		return &UserInfo{
+			Username: cert.Subject.CommonName,
+			Groups:   cert.Subject.Organization,
+		}, nil
+	}
+	token := cfg.BearerToken
+	if token == "" && cfg.BearerTokenFile != "" {
+		b, err := os.ReadFile(cfg.BearerTokenFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read bearer token")
+		}
+		token = strings.TrimSpace(string(b))
+	}
+	if u := userInfoFromServiceAccountToken(token); u != nil {
+		return u, nil
+	}
+	return nil, errors.New("SelfSubjectReview is not available and the identity cannot be derived from the credentials")
+}
+
+// userInfoFromServiceAccountToken reads the subject of a service account
+// token without verifying it. It returns nil if token is not a service
+// account token.
+func userInfoFromServiceAccountToken(token string) *UserInfo {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil
+	}
+	claims := struct {
+		Subject string `json:"sub"`
+	}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil
+	}
+	// system:serviceaccount:<namespace>:<name>
+	sub := strings.Split(claims.Subject, ":")
+	if len(sub) != 4 || sub[0] != "system" || sub[1] != "serviceaccount" {
+		return nil
+	}
+	return &UserInfo{
+		Username: claims.Subject,
+		Groups:   []string{"system:serviceaccounts", "system:serviceaccounts:" + sub[2], "system:authenticated"},
+	}
 }
@@ -4,24 +4,151 @@
 package klient
 
 import (
+	"time"
+
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/transport"
 	"sigs.k8s.io/e2e-framework/klient"
 )
 
 type Client = klient.Client
 
-// NewClientFromConfigBytes creates a new kube client using the provided config.
-func NewClientFromConfig(cfg *rest.Config) (Client, error) {
-	if cfg.WrapTransport == nil {
-		cfg.WrapTransport = newRetryTransportWrapper()
+// clientConfig is modified by ClientOptions before a new kube client is
+// created.
+type clientConfig struct {
+	rest        *rest.Config
+	retryPolicy *RetryPolicy
+	wrappers    []transport.WrapperFunc
+	// keepTransport keeps the transport wrappers of the config, including its
+	// retry transport, as they are.
+	keepTransport bool
+}
+
+// ClientOption modifies the configuration of a new kube client.
+type ClientOption func(c *clientConfig)
+
+// WithQPS limits the requests per second of the client to qps with bursts
+// of up to burst requests. A negative qps disables client-side rate limiting.
+func WithQPS(qps float32, burst int) ClientOption {
+	return func(c *clientConfig) {
+		c.rest.QPS = qps
+		c.rest.Burst = burst
+		c.rest.RateLimiter = nil
+	}
+}
+
+// WithTimeout sets the timeout of a single request.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *clientConfig) {
+		c.rest.Timeout = timeout
+	}
+}
+
+// WithCompression enables or disables the compression of responses.
+func WithCompression(enabled bool) ClientOption {
+	return func(c *clientConfig) {
+		c.rest.DisableCompression = !enabled
+	}
+}
+
+// WithRetryPolicy retries requests according to policy instead of the
+// DefaultRetryPolicy. Use a policy with zero MaxRetries to disable retries.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *clientConfig) {
+		c.retryPolicy = &policy
+	}
+}
+
+// WithTransportWrapper adds a wrapper around the transport of the client. It
+// is wrapped by the retry transport, so every attempt passes the wrapper.
+func WithTransportWrapper(wrapper transport.WrapperFunc) ClientOption {
+	return func(c *clientConfig) {
+		c.wrappers = append(c.wrappers, wrapper)
+	}
+}
+
+// WithUserAgent sets the user agent of all requests.
+func WithUserAgent(userAgent string) ClientOption {
+	return func(c *clientConfig) {
+		c.rest.UserAgent = userAgent
+	}
+}
+
+// WithImpersonation performs all requests as the given user.
+func WithImpersonation(impersonate rest.ImpersonationConfig) ClientOption {
+	return func(c *clientConfig) {
+		c.rest.Impersonate = impersonate
+	}
+}
+
+// withExistingTransport keeps the transport wrappers of the config, including
+// the retry transport and its policy, instead of installing a new retry
+// transport. It is used to derive clients from an existing client.
+func withExistingTransport() ClientOption {
+	return func(c *clientConfig) {
+		c.keepTransport = true
+		c.retryPolicy = nil
+	}
+}
+
+// WithEKSPreset configures the client for EKS-like control planes that scale
+// with load: compression and client-side rate limiting are disabled, the
+// request timeout is 5 minutes and requests are retried with the
+// DefaultRetryPolicy.
+//
+// See https://docs.aws.amazon.com/eks/latest/best-practices/scale-control-plane.html
+func WithEKSPreset() ClientOption {
+	return func(c *clientConfig) {
+		c.rest.DisableCompression = true
+		c.rest.QPS = -1
+		c.rest.RateLimiter = nil
+		c.rest.Timeout = 5 * time.Minute
+		policy := DefaultRetryPolicy()
+		c.retryPolicy = &policy
+	}
+}
+
+// NewClient creates a new kube client from a copy of cfg. Requests are
+// retried with the DefaultRetryPolicy unless WithRetryPolicy is given. An
+// existing transport wrapper of cfg is kept and wrapped by the retry
+// transport. The retry transport installed by SetConfigParameter is replaced
+// instead, so requests are never retried twice.
+func NewClient(cfg *rest.Config, opts ...ClientOption) (Client, error) {
+	policy := DefaultRetryPolicy()
+	c := &clientConfig{
+		rest:        rest.CopyConfig(cfg),
+		retryPolicy: &policy,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	if !c.keepTransport && isRetryTransportWrapper(c.rest.WrapTransport) {
+		c.rest.WrapTransport = nil
+	}
+	for _, w := range c.wrappers {
+		c.rest.Wrap(w)
+	}
+	if c.retryPolicy != nil && c.retryPolicy.MaxRetries > 0 {
+		c.rest.Wrap(newRetryTransportWrapper(*c.retryPolicy))
+	}
+	return klient.New(c.rest)
+}
+
+// NewClientFromConfig creates a new kube client using the provided config.
+// Requests are retried with the DefaultRetryPolicy unless the config already
+// has a transport wrapper other than the one set by SetConfigParameter, or
+// another policy is given with WithRetryPolicy.
+func NewClientFromConfig(cfg *rest.Config, opts ...ClientOption) (Client, error) {
+	if cfg.WrapTransport != nil && !isRetryTransportWrapper(cfg.WrapTransport) {
+		opts = append([]ClientOption{WithRetryPolicy(RetryPolicy{})}, opts...)
 	}
-	return klient.New(cfg)
+	return NewClient(cfg, opts...)
 }
 
 // NewClientFromConfigBytes creates a new kube client using the provided config
-// file.
-func NewClientFromConfigBytes(configBytes []byte) (Client, error) {
+// file. The client uses WithEKSPreset, which can be overridden by opts.
+func NewClientFromConfigBytes(configBytes []byte, opts ...ClientOption) (Client, error) {
 	config, err := clientcmd.NewClientConfigFromBytes(configBytes)
 	if err != nil {
 		return nil, err
@@ -30,6 +157,5 @@ func NewClientFromConfigBytes(configBytes []byte) (Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	SetConfigParameter(restConfig)
-	return NewClientFromConfig(restConfig)
+	return NewClient(restConfig, append([]ClientOption{WithEKSPreset()}, opts...)...)
 }
@@ -0,0 +1,55 @@
+// SPDX-FileCopyrightText: Copyright DB InfraGO AG and contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package klient
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/e2e-framework/klient"
+)
+
+// NewImpersonatingClient derives a client from kube that performs all
+// requests as the given user and groups. The client of kube must be allowed
+// to impersonate them. The transport of kube, including its retry policy, is
+// kept as it is.
+func NewImpersonatingClient(kube klient.Client, username string, groups ...string) (Client, error) {
+	return NewClient(kube.RESTConfig(), withExistingTransport(), WithImpersonation(rest.ImpersonationConfig{
+		UserName: username,
+		Groups:   groups,
+	}))
+}
+
+// CanI returns whether the user of kube is allowed to perform the action
+// described by attrs in the same way "kubectl auth can-i" does it.
+func CanI(ctx context.Context, kube klient.Client, attrs authorizationv1.ResourceAttributes) (bool, error) {
+	ssar := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &attrs,
+		},
+	}
+	if err := kube.Resources().GetControllerRuntimeClient().Create(ctx, ssar); err != nil {
+		return false, errors.Wrap(err, "cannot create SelfSubjectAccessReview")
+	}
+	return ssar.Status.Allowed, nil
+}
+
+// SubjectCan returns whether the given user and groups are allowed to perform
+// the action described by attrs. The user of kube must be allowed to create
+// SubjectAccessReviews.
+func SubjectCan(ctx context.Context, kube klient.Client, username string, groups []string, attrs authorizationv1.ResourceAttributes) (bool, error) {
+	sar := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &attrs,
+			User:               username,
+			Groups:             groups,
+		},
+	}
+	if err := kube.Resources().GetControllerRuntimeClient().Create(ctx, sar); err != nil {
+		return false, errors.Wrap(err, "cannot create SubjectAccessReview")
+	}
+	return sar.Status.Allowed, nil
+}
@@ -5,56 +5,188 @@ package klient
 
 import (
 	"errors"
+	"io"
+	"math/rand/v2"
 	"net/http"
+	"slices"
+	"strconv"
+	"syscall"
 	"time"
 
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/transport"
 )
 
+// SetConfigParameter applies the settings of WithEKSPreset to cfg in place.
+// Prefer NewClient with WithEKSPreset, which allows to override single
+// settings.
 func SetConfigParameter(cfg *rest.Config) {
-	cfg.WrapTransport = newRetryTransportWrapper()
+	cfg.WrapTransport = newRetryTransportWrapper(DefaultRetryPolicy())
 	cfg.DisableCompression = true //https://docs.aws.amazon.com/eks/latest/best-practices/scale-control-plane.html
 	cfg.QPS = -1
 	cfg.RateLimiter = nil
 	cfg.Timeout = 5 * time.Minute
 }
 
+// RetryPolicy defines which requests are retried by the retry transport and
+// how long it waits between attempts.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt.
+	MaxRetries int
+	// InitialBackoff is the wait time before the first retry. It is doubled
+	// for every further retry up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter is the fraction of the backoff that is randomly added to it.
+	Jitter float64
+	// RetryableStatusCodes are response status codes that are retried. A
+	// Retry-After header of these responses overrides the backoff, but is
+	// capped at MaxBackoff.
+	RetryableStatusCodes []int
+	// IsRetryableError returns true if a request that failed with the error
+	// can be retried.
+	IsRetryableError func(err error) bool
+}
+
+// DefaultRetryPolicy retries transient connection errors as well as
+// responses with the status codes 429 and 503.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:           3,
+		InitialBackoff:       time.Second,
+		MaxBackoff:           30 * time.Second,
+		Jitter:               0.2,
+		RetryableStatusCodes: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+		IsRetryableError:     isTransientError,
+	}
+}
+
+// backoff returns the wait time before the given retry, starting at 0.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.InitialBackoff
+	for range retry {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			d = p.MaxBackoff
+			break
+		}
+	}
+	if p.Jitter > 0 {
+		d += time.Duration(rand.Float64() * p.Jitter * float64(d)) //nolint:gosec // jitter does not need a secure source
+	}
+	return d
+}
+
 // retryTransport wraps an existing RoundTripper and retries on transient errors.
 type retryTransport struct {
 	RoundTripper http.RoundTripper
-	MaxRetries   int
-	Backoff      time.Duration
+	Policy       RetryPolicy
 }
 
 // newRetryTransportWrapper creates a transport.WrapperFunc that performs a
 // retry if the http client connection is lost
-// (which happens frequently on mdp environments using VDS).
-func newRetryTransportWrapper() transport.WrapperFunc {
+// (which happens frequently on mdp environments using VDS) or the API server
+// is overloaded.
+func newRetryTransportWrapper(policy RetryPolicy) transport.WrapperFunc {
 	return func(rt http.RoundTripper) http.RoundTripper {
 		return &retryTransport{
 			RoundTripper: rt,
-			MaxRetries:   3,
-			Backoff:      3 * time.Second,
+			Policy:       policy,
 		}
 	}
 }
 
-// RoundTrip retries the request on transient errors.
+// isRetryTransportWrapper returns true if wrap only installs a retry
+// transport, as SetConfigParameter does.
+func isRetryTransportWrapper(wrap transport.WrapperFunc) bool {
+	if wrap == nil {
+		return false
+	}
+	probe := http.RoundTripper(&http.Transport{})
+	rt, ok := wrap(probe).(*retryTransport)
+	return ok && rt.RoundTripper == probe
+}
+
+// RoundTrip retries the request on transient errors and retryable status
+// codes. Requests with a body are only retried if the body can be rewound
+// using GetBody. It stops retrying once the context of the request is done.
+// A Retry-After header of the response is honoured up to MaxBackoff.
 func (rt *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	var resp *http.Response
-	var err error
-	for range rt.MaxRetries {
-		resp, err = rt.RoundTripper.RoundTrip(req)
-		if err == nil || !isTransientError(err) {
+	ctx := req.Context()
+	canRewind := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	attempt := req
+	for retry := 0; ; retry++ {
+		resp, err := rt.RoundTripper.RoundTrip(attempt)
+		if retry >= rt.Policy.MaxRetries || !canRewind || ctx.Err() != nil {
 			return resp, err
 		}
-		time.Sleep(rt.Backoff) // Backoff before retrying
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if rt.Policy.IsRetryableError == nil || !rt.Policy.IsRetryableError(err) {
+				return resp, err
+			}
+			wait = rt.Policy.backoff(retry)
+		case slices.Contains(rt.Policy.RetryableStatusCodes, resp.StatusCode):
+			wait = rt.Policy.backoff(retry)
+			if after, ok := retryAfter(resp); ok {
+				wait = after
+				if rt.Policy.MaxBackoff > 0 {
+					wait = min(wait, rt.Policy.MaxBackoff)
+				}
+			}
+			// hand the response to the caller if the request cannot be
+			// retried before its deadline anyway
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+				return resp, nil
+			}
+			// drain the body to reuse the connection
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		default:
+			return resp, nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		attempt = req.Clone(ctx)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attempt.Body = body
+		}
+	}
+}
+
+// retryAfter parses the Retry-After header of resp which is either given in
+// seconds or as HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
 	}
-	return resp, err
+	return 0, false
 }
 
 // isTransientError checks if the error is transient (e.g., connection lost).
 func isTransientError(err error) bool {
-	return err != nil && (errors.Is(err, http.ErrServerClosed) || err.Error() == "http2: client connection lost" || err.Error() == "context deadline exceeded")
+	return err != nil && (errors.Is(err, http.ErrServerClosed) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		err.Error() == "http2: client connection lost" ||
+		err.Error() == "context deadline exceeded")
 }